@@ -0,0 +1,62 @@
+package converter
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/xwb1989/sqlparser"
+)
+
+// separatorPrefix starts the SEPARATOR clause as sqlparser records it.
+const separatorPrefix = " separator '"
+
+// mapGroupConcatExpr rewrites GROUP_CONCAT into string_agg. Several
+// arguments are concatenated as text, the separator defaults to a comma as in
+// MySQL, and DISTINCT and ORDER BY move into the aggregate call.
+func (mapper *SQLMapper) mapGroupConcatExpr(e *sqlparser.GroupConcatExpr) string {
+	mapper.aggregates++
+	defer func() { mapper.aggregates-- }()
+
+	c := mapper.newFuncCall("GROUP_CONCAT", e.Exprs)
+	values := make([]string, len(c.args))
+	for i, expr := range c.exprs {
+		if expr == nil {
+			return mapper.fail(fmt.Errorf("function GROUP_CONCAT: * not supported"))
+		}
+		values[i] = c.text(i)
+	}
+	value := strings.Join(values, " || ")
+
+	separator := ","
+	if strings.HasPrefix(e.Separator, separatorPrefix) {
+		separator = strings.TrimSuffix(strings.TrimPrefix(e.Separator, separatorPrefix), "'")
+	}
+
+	args := value + ", '" + strings.ReplaceAll(separator, "'", "''") + "'"
+	if e.Distinct != "" {
+		args = "DISTINCT " + args
+	}
+
+	if len(e.OrderBy) > 0 {
+		orderByParts := make([]string, 0, len(e.OrderBy))
+		for _, order := range e.OrderBy {
+			orderStr := ""
+			if e.Distinct != "" {
+				// PostgreSQL only orders a DISTINCT aggregate by its argument.
+				if len(c.exprs) != 1 || sqlparser.String(order.Expr) != sqlparser.String(c.exprs[0]) {
+					return mapper.fail(fmt.Errorf("function GROUP_CONCAT: DISTINCT can only be ordered by its argument"))
+				}
+				orderStr = value
+			} else {
+				orderStr = mapper.mapExpr(order.Expr)
+			}
+			if order.Direction != "" {
+				orderStr += " " + order.Direction
+			}
+			orderByParts = append(orderByParts, orderStr)
+		}
+		args += " ORDER BY " + strings.Join(orderByParts, ", ")
+	}
+
+	return "string_agg(" + args + ")"
+}
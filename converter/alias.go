@@ -0,0 +1,48 @@
+package converter
+
+import (
+	"regexp"
+	"strings"
+)
+
+// plainIdent matches identifiers PostgreSQL accepts without quoting. Upper
+// case letters are folded the same way in an alias and in every reference
+// to it, so they need no quotes either.
+var plainIdent = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_$]*$`)
+
+// reservedWords are the PostgreSQL keywords that cannot name a table or
+// column alias unquoted.
+var reservedWords = map[string]struct{}{}
+
+func init() {
+	for _, word := range strings.Fields(`
+		all analyse analyze and any array as asc asymmetric authorization
+		binary both case cast check collate collation column concurrently
+		constraint create cross current_catalog current_date current_role
+		current_schema current_time current_timestamp current_user default
+		deferrable desc distinct do else end except false fetch for foreign
+		freeze from full grant group having ilike in initially inner
+		intersect into is isnull join lateral leading left like limit
+		localtime localtimestamp natural not notnull null offset on only or
+		order outer overlaps placing primary references returning right
+		select session_user similar some symmetric system_user table
+		tablesample then to trailing true union unique user using variadic
+		verbose when where window with`) {
+		reservedWords[word] = struct{}{}
+	}
+}
+
+// quoteIdent renders a table qualifier or an output column name as a
+// PostgreSQL identifier. Every topic reference is emitted under its
+// qualifier, which is the topic name when the query gives no alias, and
+// output columns are named after JSON keys, so names that are reserved or
+// contain other characters are double-quoted to keep them intact.
+func quoteIdent(name string) string {
+	if name == "" {
+		return ""
+	}
+	if _, reserved := reservedWords[strings.ToLower(name)]; !reserved && plainIdent.MatchString(name) {
+		return name
+	}
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
@@ -0,0 +1,68 @@
+package converter
+
+import (
+	"testing"
+
+	"sqlalchemy/db"
+)
+
+var arrayCatalog = db.StaticCatalog{
+	"pump_alarm": {
+		"code":      db.FieldText,
+		"threshold": db.FieldNumeric,
+		"readings":  db.FieldObjectArray,
+		"tags":      db.FieldArray,
+	},
+	"pump_alarm.readings": {"value": db.FieldNumeric},
+	"pump_alarm.tags":     {"value": db.FieldText},
+}
+
+// TestArrayTables checks how parent.field table references are resolved.
+func TestArrayTables(t *testing.T) {
+	tests := []struct {
+		name string
+		sql  string
+		want string
+	}{
+		{
+			name: "implicit parent",
+			sql:  "SELECT r.value FROM pump_alarm.readings r",
+			want: "SELECT (r.payload ->> 'value')::NUMERIC AS value " +
+				"FROM tsdb_table AS pump_alarm CROSS JOIN LATERAL jsonb_array_elements(pump_alarm.payload -> 'readings') AS r(payload) " +
+				"WHERE (pump_alarm.payload ->> 'topic') = 'pump_alarm'",
+		},
+		{
+			name: "correlated parent",
+			sql:  "SELECT code FROM pump_alarm p WHERE EXISTS (SELECT 1 FROM p.readings r WHERE r.value > p.threshold)",
+			want: "SELECT (payload ->> 'code') AS code FROM tsdb_table AS p WHERE (p.payload ->> 'topic') = 'pump_alarm' " +
+				"AND EXISTS (SELECT 1 FROM LATERAL jsonb_array_elements(p.payload -> 'readings') AS r(payload) " +
+				"WHERE (r.payload ->> 'value')::NUMERIC > (p.payload ->> 'threshold')::NUMERIC)",
+		},
+		{
+			name: "schema-qualified topic",
+			sql:  "SELECT code FROM myschema.pump_alarm",
+			want: "SELECT (payload ->> 'code') AS code FROM tsdb_table AS pump_alarm WHERE (payload ->> 'topic') = 'pump_alarm'",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mapper, err := NewSQLMapper(tt.sql, arrayCatalog, "tsdb_table", "payload", db.TopicKey{Name: "topic"}, nil)
+			if err != nil {
+				t.Fatalf("NewSQLMapper: %v", err)
+			}
+			if mapper.MappedSQL != tt.want {
+				t.Errorf("mapped SQL\n got: %s\nwant: %s", mapper.MappedSQL, tt.want)
+			}
+		})
+	}
+}
+
+// TestUnknownArrayTable checks that a qualified table that is neither an
+// array field nor a topic is rejected.
+func TestUnknownArrayTable(t *testing.T) {
+	_, err := NewSQLMapper("SELECT 1 FROM myschema.nothing", arrayCatalog, "tsdb_table", "payload", db.TopicKey{Name: "topic"}, nil)
+	if err == nil {
+		t.Fatal("NewSQLMapper: expected an error")
+	}
+}
@@ -0,0 +1,159 @@
+package converter
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/xwb1989/sqlparser"
+)
+
+// boundColumn resolves a column reference that names a real column instead
+// of a JSONB field of a topic, and returns the SQL that reads it:
+//   - a column of a derived table or WITH query, read from that relation;
+//   - a select-list alias, replaced by the expression it names since
+//     PostgreSQL only accepts bare output names in ORDER BY. In HAVING the
+//     alias wins unless GROUP BY names the column, as in MySQL; in ORDER BY
+//     only when no table of the query has the column. Aliases are not
+//     substituted inside aggregate arguments, where they would nest
+//     aggregates.
+//
+// Everything else is a topic field and is left to columnAccess.
+func (mapper *SQLMapper) boundColumn(col *sqlparser.ColName) (string, bool) {
+	if mapper.scope == nil {
+		return "", false
+	}
+	written := strings.ReplaceAll(col.Name.String(), ".", nestedSeparator)
+	name := strings.ToLower(written)
+
+	if !col.Qualifier.IsEmpty() {
+		q := col.Qualifier.Name.String()
+		if ref, ok := mapper.scope.lookup(q); ok && ref.derived {
+			if column, ok := ref.columns[name]; ok {
+				written = column
+			}
+			return quoteIdent(q) + "." + quoteIdent(written), true
+		}
+		return "", false
+	}
+
+	if expr, ok := mapper.scope.aliases[name]; ok && mapper.aggregates == 0 {
+		if mapper.scope.having {
+			if _, ok := mapper.scope.grouped[name]; !ok {
+				return expr, true
+			}
+		} else if !mapper.hasColumn(mapper.scope, col) {
+			return expr, true
+		}
+	}
+	for s := mapper.scope; s != nil; s = s.parent {
+		owners := mapper.columnOwners(s, col)
+		if len(owners) > 1 {
+			return mapper.fail(fmt.Errorf("column %s is ambiguous: it is a column of %s", sqlparser.String(col), strings.Join(owners, ", "))), true
+		}
+		if len(owners) == 1 {
+			ref := s.tables[owners[0]]
+			if !ref.derived {
+				return "", false
+			}
+			return quoteIdent(owners[0]) + "." + quoteIdent(ref.columns[name]), true
+		}
+		if s.payloadTables() == 0 {
+			if len(s.order) > 0 && s.hasOpenDerived() {
+				// Only derived tables whose columns are not all known.
+				return quoteIdent(written), true
+			}
+			continue
+		}
+		if mapper.Catalog == nil {
+			return "", false
+		}
+		// No table of this query has the column: look further out, as
+		// resolveQualifier does for correlated references.
+	}
+	return "", false
+}
+
+// hasColumn reports whether a table of s has the column: a derived table
+// listing it or whose columns are unknown, or a topic with the field.
+func (mapper *SQLMapper) hasColumn(s *scope, col *sqlparser.ColName) bool {
+	return s.hasOpenDerived() || len(mapper.columnOwners(s, col)) > 0
+}
+
+// hasOpenDerived reports whether a derived table of s has columns that are
+// not known, such as those of an unexpanded *.
+func (s *scope) hasOpenDerived() bool {
+	for _, ref := range s.tables {
+		if ref.derived && ref.columns == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// derivedColumns returns the output column names of a subquery, as the
+// mapped query names them, or nil when a * leaves them unknown.
+func derivedColumns(stmt sqlparser.SelectStatement) map[string]string {
+	switch s := stmt.(type) {
+	case *sqlparser.Select:
+		exprs := s.SelectExprs
+		if distinctOnExpr(exprs) != nil {
+			exprs = exprs[1:]
+		}
+		names := make([]string, 0, len(exprs))
+		for _, expr := range exprs {
+			ae, ok := expr.(*sqlparser.AliasedExpr)
+			if !ok {
+				return nil
+			}
+			name := ae.As.String()
+			if name == "" {
+				switch e := ae.Expr.(type) {
+				case *sqlparser.ColName:
+					name = strings.ReplaceAll(e.Name.String(), ".", nestedSeparator)
+				case *sqlparser.FuncExpr:
+					name = e.Name.String()
+				}
+			}
+			if name != "" {
+				names = append(names, name)
+			}
+		}
+		return columnSet(names)
+	case *sqlparser.Union:
+		return derivedColumns(s.Left)
+	case *sqlparser.ParenSelect:
+		return derivedColumns(s.Select)
+	}
+	return nil
+}
+
+// columnSet indexes column names by their lower-case form, since MySQL
+// matches column names without regard to case, and keeps the spelling the
+// mapped query uses for them.
+func columnSet(names []string) map[string]string {
+	set := make(map[string]string, len(names))
+	for _, name := range names {
+		set[strings.ToLower(name)] = name
+	}
+	return set
+}
+
+// selectAliases maps the explicit aliases of a select list to the mapped
+// expressions they name.
+func (mapper *SQLMapper) selectAliases(exprs sqlparser.SelectExprs) map[string]string {
+	aliases := make(map[string]string)
+	for _, expr := range exprs {
+		ae, ok := expr.(*sqlparser.AliasedExpr)
+		if !ok || ae.As.IsEmpty() {
+			continue
+		}
+		mapped := mapper.mapExpr(ae.Expr)
+		switch ae.Expr.(type) {
+		case *sqlparser.ColName, *sqlparser.FuncExpr, *sqlparser.SQLVal, *sqlparser.ParenExpr:
+		default:
+			mapped = "(" + mapped + ")"
+		}
+		aliases[strings.ToLower(ae.As.String())] = mapped
+	}
+	return aliases
+}
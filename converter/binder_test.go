@@ -0,0 +1,111 @@
+package converter
+
+import (
+	"testing"
+
+	"sqlalchemy/db"
+)
+
+var binderCatalog = db.StaticCatalog{
+	"pump_alarm": {"value": db.FieldNumeric, "code": db.FieldText, "pump_id": db.FieldInteger},
+}
+
+// TestHavingAliases checks which of a select-list alias and a column of the
+// same name HAVING reads.
+func TestHavingAliases(t *testing.T) {
+	tests := []struct {
+		name string
+		sql  string
+		want string
+	}{
+		{
+			name: "alias shadows a field",
+			sql:  "SELECT SUM(value) AS value FROM pump_alarm GROUP BY code HAVING value > 0",
+			want: "SELECT SUM((payload ->> 'value')::NUMERIC) AS value FROM tsdb_table AS pump_alarm " +
+				"WHERE (payload ->> 'topic') = 'pump_alarm' GROUP BY (payload ->> 'code') HAVING SUM((payload ->> 'value')::NUMERIC) > 0",
+		},
+		{
+			name: "grouped column shadows an alias",
+			sql:  "SELECT code AS c, COUNT(*) AS code FROM pump_alarm GROUP BY code HAVING code != 'x'",
+			want: "SELECT (payload ->> 'code') AS c, COUNT(*) AS code FROM tsdb_table AS pump_alarm " +
+				"WHERE (payload ->> 'topic') = 'pump_alarm' GROUP BY (payload ->> 'code') HAVING (payload ->> 'code') != 'x'",
+		},
+		{
+			name: "alias inside an aggregate",
+			sql:  "SELECT SUM(value) AS value FROM pump_alarm GROUP BY code HAVING MAX(value) > 1",
+			want: "SELECT SUM((payload ->> 'value')::NUMERIC) AS value FROM tsdb_table AS pump_alarm " +
+				"WHERE (payload ->> 'topic') = 'pump_alarm' GROUP BY (payload ->> 'code') HAVING MAX((payload ->> 'value')::NUMERIC) > 1",
+		},
+		{
+			name: "order by prefers the field",
+			sql:  "SELECT SUM(value) AS value FROM pump_alarm GROUP BY code ORDER BY value",
+			want: "SELECT SUM((payload ->> 'value')::NUMERIC) AS value FROM tsdb_table AS pump_alarm " +
+				"WHERE (payload ->> 'topic') = 'pump_alarm' GROUP BY (payload ->> 'code') ORDER BY value asc",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mapper, err := NewSQLMapper(tt.sql, binderCatalog, "tsdb_table", "payload", db.TopicKey{Name: "topic"}, nil)
+			if err != nil {
+				t.Fatalf("NewSQLMapper: %v", err)
+			}
+			if mapper.MappedSQL != tt.want {
+				t.Errorf("mapped SQL\n got: %s\nwant: %s", mapper.MappedSQL, tt.want)
+			}
+		})
+	}
+}
+
+// TestDerivedColumns checks that columns of derived tables and WITH queries
+// are read from the relation instead of a payload.
+func TestDerivedColumns(t *testing.T) {
+	tests := []struct {
+		name string
+		sql  string
+		want string
+	}{
+		{
+			name: "qualified derived column",
+			sql:  "SELECT s.total FROM (SELECT SUM(value) AS total FROM pump_alarm) s",
+			want: "SELECT s.total AS total " +
+				"FROM (SELECT SUM((payload ->> 'value')::NUMERIC) AS total FROM tsdb_table AS pump_alarm WHERE (payload ->> 'topic') = 'pump_alarm') AS s",
+		},
+		{
+			name: "unqualified derived column",
+			sql:  "SELECT total FROM (SELECT SUM(value) AS total FROM pump_alarm) s WHERE total > 1",
+			want: "SELECT s.total AS total " +
+				"FROM (SELECT SUM((payload ->> 'value')::NUMERIC) AS total FROM tsdb_table AS pump_alarm WHERE (payload ->> 'topic') = 'pump_alarm') AS s " +
+				"WHERE s.total > 1",
+		},
+		{
+			name: "with query",
+			sql:  "WITH t AS (SELECT code, pump_id FROM pump_alarm) SELECT code FROM t WHERE pump_id = 1",
+			want: "WITH t AS (SELECT (payload ->> 'code') AS code, (payload ->> 'pump_id')::BIGINT AS pump_id FROM tsdb_table AS pump_alarm WHERE (payload ->> 'topic') = 'pump_alarm') " +
+				"SELECT t.code AS code FROM t WHERE t.pump_id = 1",
+		},
+		{
+			name: "with query column list",
+			sql:  "WITH t(c) AS (SELECT code FROM pump_alarm) SELECT c FROM t",
+			want: "WITH t(c) AS (SELECT (payload ->> 'code') AS code FROM tsdb_table AS pump_alarm WHERE (payload ->> 'topic') = 'pump_alarm') " +
+				"SELECT t.c AS c FROM t",
+		},
+		{
+			name: "order by alias",
+			sql:  "SELECT code AS c FROM pump_alarm ORDER BY c",
+			want: "SELECT (payload ->> 'code') AS c FROM tsdb_table AS pump_alarm WHERE (payload ->> 'topic') = 'pump_alarm' ORDER BY c asc",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mapper, err := NewSQLMapper(tt.sql, binderCatalog, "tsdb_table", "payload", db.TopicKey{Name: "topic"}, nil)
+			if err != nil {
+				t.Fatalf("NewSQLMapper: %v", err)
+			}
+			if mapper.MappedSQL != tt.want {
+				t.Errorf("mapped SQL\n got: %s\nwant: %s", mapper.MappedSQL, tt.want)
+			}
+		})
+	}
+}
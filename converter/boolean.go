@@ -0,0 +1,91 @@
+package converter
+
+import (
+	"strings"
+
+	"github.com/xwb1989/sqlparser"
+)
+
+// mapOrExpr maps an OR chain. Operands joined by xorMarker form XOR groups,
+// which bind tighter than OR and looser than AND as in MySQL; each group is
+// rewritten to a chain of boolean inequalities.
+func (mapper *SQLMapper) mapOrExpr(e *sqlparser.OrExpr) string {
+	var terms []sqlparser.Expr
+	flattenOr(e, &terms)
+
+	var groups [][]sqlparser.Expr
+	xor := false
+	for _, term := range terms {
+		switch {
+		case isXorMarker(term):
+			xor = true
+			continue
+		case xor && len(groups) > 0:
+			groups[len(groups)-1] = append(groups[len(groups)-1], term)
+		default:
+			groups = append(groups, []sqlparser.Expr{term})
+		}
+		xor = false
+	}
+
+	parts := make([]string, 0, len(groups))
+	for _, group := range groups {
+		if len(group) == 1 {
+			parts = append(parts, mapper.mapExpr(group[0]))
+			continue
+		}
+		mapped := mapper.mapBoolOperand(group[0])
+		for _, operand := range group[1:] {
+			mapped = "(" + mapped + ") <> (" + mapper.mapBoolOperand(operand) + ")"
+		}
+		parts = append(parts, mapped)
+	}
+	return strings.Join(parts, " OR ")
+}
+
+func flattenOr(expr sqlparser.Expr, terms *[]sqlparser.Expr) {
+	if or, ok := expr.(*sqlparser.OrExpr); ok {
+		flattenOr(or.Left, terms)
+		flattenOr(or.Right, terms)
+		return
+	}
+	*terms = append(*terms, expr)
+}
+
+func isXorMarker(expr sqlparser.Expr) bool {
+	col, ok := expr.(*sqlparser.ColName)
+	return ok && col.Qualifier.IsEmpty() && col.Name.String() == xorMarker
+}
+
+// mapIsExpr maps IS [NOT] NULL/TRUE/FALSE. NULL checks read the raw field
+// without a cast; truth checks coerce the operand to boolean first.
+func (mapper *SQLMapper) mapIsExpr(e *sqlparser.IsExpr) string {
+	operator := strings.ToUpper(e.Operator)
+	switch e.Operator {
+	case sqlparser.IsNullStr, sqlparser.IsNotNullStr:
+		if col, ok := e.Expr.(*sqlparser.ColName); ok {
+			return mapper.columnAccess(col) + " " + operator
+		}
+		return mapper.mapExpr(e.Expr) + " " + operator
+	default:
+		return mapper.mapBoolOperand(e.Expr) + " " + operator
+	}
+}
+
+// mapBoolOperand maps an expression used as a truth value. Boolean fields
+// keep their cast, numeric fields are true when non-zero as in MySQL, and
+// fields without a known type are read as boolean text.
+func (mapper *SQLMapper) mapBoolOperand(expr sqlparser.Expr) string {
+	col, ok := expr.(*sqlparser.ColName)
+	if !ok {
+		return mapper.mapExpr(expr)
+	}
+	switch cast := mapper.fieldCast(col); cast {
+	case "::BOOLEAN":
+		return mapper.mapExpr(col)
+	case "::BIGINT", "::NUMERIC", "::FLOAT":
+		return "(" + mapper.mapExpr(col) + " <> 0)"
+	default:
+		return mapper.columnAccess(col) + "::BOOLEAN"
+	}
+}
@@ -0,0 +1,139 @@
+package converter
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/xwb1989/sqlparser"
+)
+
+// mapCaseExpr maps the operand, every WHEN condition, every THEN value and
+// the ELSE value. When the branch results disagree on their type, e.g. a
+// numeric field and a non-numeric string, every branch is projected as text
+// so PostgreSQL can resolve a common result type.
+func (mapper *SQLMapper) mapCaseExpr(e *sqlparser.CaseExpr) string {
+	parts := []string{"CASE"}
+	operandCast := ""
+	if e.Expr != nil {
+		parts = append(parts, mapper.mapExpr(e.Expr))
+		if col, ok := e.Expr.(*sqlparser.ColName); ok {
+			operandCast = mapper.fieldCast(col)
+		}
+	}
+
+	results := make([]sqlparser.Expr, 0, len(e.Whens)+1)
+	for _, when := range e.Whens {
+		results = append(results, when.Val)
+	}
+	if e.Else != nil {
+		results = append(results, e.Else)
+	}
+	asText := mapper.branchesConflict(results)
+
+	for _, when := range e.Whens {
+		cond := ""
+		if e.Expr != nil {
+			cond = mapper.mapLiteral(when.Cond, operandCast)
+		} else {
+			cond = mapper.mapExpr(when.Cond)
+		}
+		parts = append(parts, "WHEN", cond, "THEN", mapper.mapBranch(when.Val, asText))
+	}
+	if e.Else != nil {
+		parts = append(parts, "ELSE", mapper.mapBranch(e.Else, asText))
+	}
+	parts = append(parts, "END")
+	return strings.Join(parts, " ")
+}
+
+func (mapper *SQLMapper) branchesConflict(results []sqlparser.Expr) bool {
+	kinds := make(map[string]struct{})
+	for _, result := range results {
+		if kind := mapper.branchKind(result); kind != "" {
+			kinds[kind] = struct{}{}
+		}
+	}
+	return len(kinds) > 1
+}
+
+// unknownKind is the kind of a CASE result whose type the mapper cannot
+// tell. It conflicts with every other kind, so a branch of unknown type next
+// to a typed one projects all branches as text.
+const unknownKind = "unknown"
+
+// branchKind classifies a CASE result as "text", "number", the cast of a
+// typed field or cast target, or unknownKind. Results PostgreSQL coerces to
+// any type, such as NULL or a string that reads as a number, have no kind.
+func (mapper *SQLMapper) branchKind(expr sqlparser.Expr) string {
+	switch e := expr.(type) {
+	case *sqlparser.SQLVal:
+		switch e.Type {
+		case sqlparser.IntVal, sqlparser.FloatVal:
+			return "number"
+		case sqlparser.StrVal:
+			if _, err := strconv.ParseFloat(string(e.Val), 64); err == nil {
+				return ""
+			}
+			return "text"
+		}
+		return ""
+	case *sqlparser.NullVal:
+		return ""
+	case *sqlparser.ColName:
+		switch cast := mapper.fieldCast(e); cast {
+		case "":
+			return "text"
+		case "::BIGINT", "::NUMERIC", "::FLOAT":
+			return "number"
+		default:
+			return cast
+		}
+	case *sqlparser.BinaryExpr:
+		return "number"
+	case *sqlparser.ParenExpr:
+		return mapper.branchKind(e.Expr)
+	case *sqlparser.ConvertExpr:
+		switch target := convertType(e.Type); {
+		case target == "BIGINT", strings.HasPrefix(target, "NUMERIC"):
+			return "number"
+		case target == "TEXT", strings.HasPrefix(target, "VARCHAR"):
+			return "text"
+		default:
+			return "::" + strings.SplitN(target, "(", 2)[0]
+		}
+	case *sqlparser.GroupConcatExpr:
+		return "text"
+	case *sqlparser.FuncExpr:
+		switch e.Name.Lowered() {
+		case "count", "sum", "avg":
+			return "number"
+		case "min", "max":
+			if len(e.Exprs) == 1 {
+				if ae, ok := e.Exprs[0].(*sqlparser.AliasedExpr); ok {
+					return mapper.branchKind(ae.Expr)
+				}
+			}
+		}
+	}
+	return unknownKind
+}
+
+// mapBranch maps a CASE result, as text when the branches conflict.
+func (mapper *SQLMapper) mapBranch(expr sqlparser.Expr, asText bool) string {
+	if !asText {
+		return mapper.mapExpr(expr)
+	}
+	switch e := expr.(type) {
+	case *sqlparser.ColName:
+		return mapper.columnAccess(e)
+	case *sqlparser.SQLVal:
+		if e.Type == sqlparser.IntVal || e.Type == sqlparser.FloatVal {
+			return "'" + string(e.Val) + "'"
+		}
+		return mapper.mapExpr(e)
+	case *sqlparser.NullVal:
+		return mapper.mapExpr(e)
+	default:
+		return "(" + mapper.mapExpr(e) + ")::TEXT"
+	}
+}
@@ -0,0 +1,64 @@
+package converter
+
+import (
+	"testing"
+
+	"sqlalchemy/db"
+)
+
+var caseCatalog = db.StaticCatalog{
+	"pump_alarm": {"value": db.FieldNumeric, "code": db.FieldText, "active": db.FieldBoolean},
+}
+
+// TestCaseBranchTypes checks that CASE branches of different types are all
+// projected as text, and that branches of one type are left alone.
+func TestCaseBranchTypes(t *testing.T) {
+	tests := []struct {
+		name string
+		sql  string
+		want string
+	}{
+		{
+			name: "number and text",
+			sql:  "SELECT CASE WHEN active THEN value ELSE 'none' END AS v FROM pump_alarm",
+			want: "SELECT CASE WHEN (payload ->> 'active')::BOOLEAN THEN (payload ->> 'value') ELSE 'none' END AS v " +
+				"FROM tsdb_table AS pump_alarm WHERE (payload ->> 'topic') = 'pump_alarm'",
+		},
+		{
+			name: "aggregate and text",
+			sql:  "SELECT CASE WHEN active THEN COUNT(*) ELSE 'x' END AS c FROM pump_alarm",
+			want: "SELECT CASE WHEN (payload ->> 'active')::BOOLEAN THEN (COUNT(*))::TEXT ELSE 'x' END AS c " +
+				"FROM tsdb_table AS pump_alarm WHERE (payload ->> 'topic') = 'pump_alarm'",
+		},
+		{
+			name: "aggregate and number",
+			sql:  "SELECT CASE WHEN active THEN COUNT(*) ELSE 0 END AS c FROM pump_alarm",
+			want: "SELECT CASE WHEN (payload ->> 'active')::BOOLEAN THEN COUNT(*) ELSE 0 END AS c " +
+				"FROM tsdb_table AS pump_alarm WHERE (payload ->> 'topic') = 'pump_alarm'",
+		},
+		{
+			name: "cast and text",
+			sql:  "SELECT CASE WHEN active THEN CAST(code AS SIGNED) ELSE code END AS c FROM pump_alarm",
+			want: "SELECT CASE WHEN (payload ->> 'active')::BOOLEAN THEN ((payload ->> 'code')::BIGINT)::TEXT ELSE (payload ->> 'code') END AS c " +
+				"FROM tsdb_table AS pump_alarm WHERE (payload ->> 'topic') = 'pump_alarm'",
+		},
+		{
+			name: "unknown function and number",
+			sql:  "SELECT CASE WHEN active THEN UPPER(code) ELSE 1 END AS c FROM pump_alarm",
+			want: "SELECT CASE WHEN (payload ->> 'active')::BOOLEAN THEN (UPPER((payload ->> 'code')))::TEXT ELSE '1' END AS c " +
+				"FROM tsdb_table AS pump_alarm WHERE (payload ->> 'topic') = 'pump_alarm'",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mapper, err := NewSQLMapper(tt.sql, caseCatalog, "tsdb_table", "payload", db.TopicKey{Name: "topic"}, nil)
+			if err != nil {
+				t.Fatalf("NewSQLMapper: %v", err)
+			}
+			if mapper.MappedSQL != tt.want {
+				t.Errorf("mapped SQL\n got: %s\nwant: %s", mapper.MappedSQL, tt.want)
+			}
+		})
+	}
+}
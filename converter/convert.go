@@ -0,0 +1,66 @@
+package converter
+
+import (
+	"strings"
+
+	"github.com/xwb1989/sqlparser"
+)
+
+// mapConvertExpr maps CAST(x AS type) and CONVERT(x, type). The explicit
+// cast replaces the catalog cast of a field operand instead of stacking on
+// top of it; a numeric field cast to an integer type keeps its ::NUMERIC so
+// that fractional values are rounded like MySQL does rather than rejected.
+func (mapper *SQLMapper) mapConvertExpr(e *sqlparser.ConvertExpr) string {
+	target := convertType(e.Type)
+
+	col, ok := e.Expr.(*sqlparser.ColName)
+	if !ok {
+		return "(" + mapper.mapExpr(e.Expr) + ")::" + target
+	}
+	operand := mapper.columnAccess(col)
+	if target == "BIGINT" {
+		if cast := mapper.fieldCast(col); cast == "::NUMERIC" || cast == "::FLOAT" {
+			operand += cast
+		}
+	}
+	return operand + "::" + target
+}
+
+// convertType translates a MySQL cast target to its PostgreSQL type.
+func convertType(t *sqlparser.ConvertType) string {
+	switch strings.ToLower(t.Type) {
+	case "signed", "unsigned":
+		return "BIGINT"
+	case "decimal":
+		if t.Length == nil {
+			return "NUMERIC"
+		}
+		if t.Scale == nil {
+			return "NUMERIC(" + string(t.Length.Val) + ")"
+		}
+		return "NUMERIC(" + string(t.Length.Val) + "," + string(t.Scale.Val) + ")"
+	case "char", "nchar":
+		if t.Length == nil {
+			return "TEXT"
+		}
+		return "VARCHAR(" + string(t.Length.Val) + ")"
+	case "binary":
+		return "BYTEA"
+	case "datetime":
+		if t.Length == nil {
+			return "TIMESTAMP"
+		}
+		return "TIMESTAMP(" + string(t.Length.Val) + ")"
+	case "time":
+		if t.Length == nil {
+			return "TIME"
+		}
+		return "TIME(" + string(t.Length.Val) + ")"
+	case "date":
+		return "DATE"
+	case "json":
+		return "JSONB"
+	default:
+		return strings.ToUpper(t.Type)
+	}
+}
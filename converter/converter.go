@@ -5,132 +5,167 @@ import (
 	"strings"
 
 	"github.com/xwb1989/sqlparser"
+
+	"sqlalchemy/db"
 )
 
-func NewSQLMapper(sql string, numericFields map[string]struct{}, table, payloadCol, topic string) (*SQLMapper, error) {
+func NewSQLMapper(sql string, catalog db.Catalog, table, payloadCol string, topic db.TopicKey, funcs map[string]FuncRewrite) (*SQLMapper, error) {
 	mapper := &SQLMapper{
-		OriginalSQL:   sql,
-		NumericFields: numericFields,
-		TableName:     table,
-		PayloadCol:    payloadCol,
-		Topic:         topic,
+		OriginalSQL: sql,
+		Catalog:     catalog,
+		TableName:   table,
+		PayloadCol:  payloadCol,
+		Topic:       topic,
+		Funcs:       funcs,
 	}
 
-	stmt, err := sqlparser.Parse(sql)
-	if err != nil {
+	if err := mapper.mapStatement(sql); err != nil {
 		return nil, err
 	}
 
-	switch stmt := stmt.(type) {
-	case *sqlparser.Select:
-		mapper.MappedSQL = mapper.mapSelectStatement(stmt)
-	case *sqlparser.Union:
-		mapper.MappedSQL = mapper.mapUnionStatement(stmt)
-	default:
-		return nil, fmt.Errorf("SQL Type not supported: %T", stmt)
+	return mapper, nil
+}
+
+func ParseAndMapSQL(sql string, numericFields map[string]struct{}) (*SQLMapper, error) {
+	mapper := &SQLMapper{
+		OriginalSQL:   sql,
+		NumericFields: numericFields,
+	}
+
+	if err := mapper.mapStatement(sql); err != nil {
+		return nil, err
 	}
 
 	return mapper, nil
 }
 
-func ParseAndMapSQL(sql string, numericFields map[string]struct{}) (*SQLMapper, error) {
-	stmt, err := sqlparser.Parse(sql)
+// mapStatement parses sql, including a leading WITH clause, and stores the
+// mapped statement in MappedSQL.
+func (mapper *SQLMapper) mapStatement(sql string) error {
+	tables, body, err := splitWith(normalizeSQL(sql))
 	if err != nil {
-		return nil, err
+		return err
+	}
+	with := ""
+	if len(tables) > 0 {
+		if with, err = mapper.mapWith(tables); err != nil {
+			return err
+		}
 	}
 
-	mapper := &SQLMapper{
-		OriginalSQL:   sql,
-		NumericFields: numericFields,
+	stmt, err := sqlparser.Parse(body)
+	if err != nil {
+		return err
 	}
 
 	switch stmt := stmt.(type) {
 	case *sqlparser.Select:
-		mapper.MappedSQL = mapper.mapSelectStatement(stmt)
+		mapper.MappedSQL = with + mapper.mapSelectStatement(stmt)
 	case *sqlparser.Union:
-		mapper.MappedSQL = mapper.mapUnionStatement(stmt)
+		mapper.MappedSQL = with + mapper.mapUnionStatement(stmt)
 	default:
-		return nil, fmt.Errorf("SQL Type not supported: %T", stmt)
+		return fmt.Errorf("SQL Type not supported: %T", stmt)
 	}
+	return mapper.err
+}
 
-	return mapper, nil
+// fail records the first mapping error and returns an empty fragment.
+func (mapper *SQLMapper) fail(err error) string {
+	if mapper.err == nil {
+		mapper.err = err
+	}
+	return ""
 }
 
 func (mapper *SQLMapper) mapSelectStatement(selectStmt *sqlparser.Select) string {
+	mapper.pushScope(selectStmt.From)
+	defer mapper.popScope()
+
+	sourceExprs := selectStmt.SelectExprs
+	distinctOn := distinctOnExpr(sourceExprs)
+	if distinctOn != nil {
+		sourceExprs = sourceExprs[1:]
+	}
+	distinct := selectStmt.Distinct != "" && distinctOn == nil
+
+	mapper.scope.outputs = mapper.outputNames(sourceExprs)
 	aliasMap := make(map[string]string)
-	selectExprs := make([]string, 0, len(selectStmt.SelectExprs))
-	for _, selectExpr := range selectStmt.SelectExprs {
+	selected := make(map[string]struct{})
+	selectExprs := make([]string, 0, len(sourceExprs))
+	for _, selectExpr := range sourceExprs {
 		mapped := mapper.mapSelectExpr(selectExpr)
 		selectExprs = append(selectExprs, mapped)
 
 		if ae, ok := selectExpr.(*sqlparser.AliasedExpr); ok {
+			if distinct {
+				selected[mapper.mapExpr(ae.Expr)] = struct{}{}
+			}
 			alias := ae.As.String()
 			if alias == "" {
 				switch col := ae.Expr.(type) {
 				case *sqlparser.ColName:
-					alias = col.Name.String()
+					alias = fieldAlias(mapper.fieldPath(col))
 				case *sqlparser.FuncExpr:
 					alias = col.Name.String()
 				default:
 					alias = sqlparser.String(ae.Expr)
 				}
 			}
-			aliasMap[alias] = alias
+			aliasMap[alias] = quoteIdent(alias)
 		}
 	}
 
 	fromClause := ""
-	hasTableCondition := false
+	whereConditions := make([]string, 0)
 	if selectStmt.From != nil {
 		fromParts := make([]string, 0, len(selectStmt.From))
 		for _, tableExpr := range selectStmt.From {
-			mappedTable, hasCondition := mapper.mapTableExprWithCondition(tableExpr)
+			mappedTable, tableConditions := mapper.mapTableExprWithCondition(tableExpr)
 			fromParts = append(fromParts, mappedTable)
-			if hasCondition {
-				hasTableCondition = true
-			}
+			whereConditions = append(whereConditions, tableConditions...)
 		}
 		fromClause = "FROM " + strings.Join(fromParts, ", ")
 	}
 
-	parts := []string{
-		"SELECT",
-		strings.Join(selectExprs, ", "),
+	parts := []string{"SELECT"}
+	switch {
+	case distinctOn != nil:
+		parts = append(parts, "DISTINCT ON ("+mapper.mapDistinctOn(distinctOn, aliasMap)+")")
+	case distinct:
+		parts = append(parts, "DISTINCT")
 	}
+	parts = append(parts, strings.Join(selectExprs, ", "))
 	if fromClause != "" {
 		parts = append(parts, fromClause)
 	}
 
-	whereConditions := make([]string, 0)
-	if hasTableCondition {
-		tableConditions := mapper.extractTableConditions(selectStmt.From)
-		if len(tableConditions) > 0 {
-			whereConditions = append(whereConditions, tableConditions...)
-		}
-	}
 	if selectStmt.Where != nil {
-		whereConditions = append(whereConditions, mapper.mapExpr(selectStmt.Where.Expr))
+		where := mapper.mapExpr(selectStmt.Where.Expr)
+		if _, ok := selectStmt.Where.Expr.(*sqlparser.OrExpr); ok && len(whereConditions) > 0 {
+			where = "(" + where + ")"
+		}
+		whereConditions = append(whereConditions, where)
 	}
 	if len(whereConditions) > 0 {
-		whereClause := ""
-		if len(whereConditions) >= 2 {
-			if _, ok := selectStmt.Where.Expr.(*sqlparser.OrExpr); ok {
-				whereClause = fmt.Sprintf("%s AND (%s)", whereConditions[0], whereConditions[1])
-			} else {
-				whereClause = strings.Join(whereConditions, " AND ")
-			}
-		} else {
-			whereClause = whereConditions[0]
-		}
-		parts = append(parts, "WHERE", whereClause)
+		parts = append(parts, "WHERE", strings.Join(whereConditions, " AND "))
 	}
 
+	mapper.scope.aliases = mapper.selectAliases(sourceExprs)
+
 	if selectStmt.GroupBy != nil {
 		groupByParts := make([]string, 0, len(selectStmt.GroupBy))
+		mapper.scope.grouped = make(map[string]struct{})
 		for _, expr := range selectStmt.GroupBy {
 			colName := ""
 			if c, ok := expr.(*sqlparser.ColName); ok {
-				colName = c.Name.String()
+				colName = fieldAlias(mapper.fieldPath(c))
+				if c.Qualifier.IsEmpty() {
+					mapper.scope.grouped[strings.ToLower(colName)] = struct{}{}
+				}
+				if _, ok := mapper.scope.aliases[strings.ToLower(colName)]; ok && mapper.hasColumn(mapper.scope, c) {
+					// Like MySQL, GROUP BY reads a column before an alias.
+					colName = ""
+				}
 			}
 			if alias, ok := aliasMap[colName]; ok {
 				groupByParts = append(groupByParts, alias)
@@ -142,46 +177,35 @@ func (mapper *SQLMapper) mapSelectStatement(selectStmt *sqlparser.Select) string
 	}
 
 	if selectStmt.Having != nil {
-		havingStr := ""
-		switch h := selectStmt.Having.Expr.(type) {
-		case *sqlparser.FuncExpr:
-			if alias, ok := aliasMap[h.Name.String()]; ok {
-				havingStr = alias
-			} else {
-				havingStr = mapper.mapExpr(selectStmt.Having.Expr)
-			}
-		case *sqlparser.ColName:
-			if alias, ok := aliasMap[h.Name.String()]; ok {
-				havingStr = alias
-			} else {
-				havingStr = mapper.mapExpr(h)
-			}
-		default:
-			havingStr = mapper.mapExpr(selectStmt.Having.Expr)
-		}
-		parts = append(parts, "HAVING", havingStr)
+		mapper.scope.having = true
+		parts = append(parts, "HAVING", mapper.mapExpr(selectStmt.Having.Expr))
+		mapper.scope.having = false
 	}
 
 	if selectStmt.OrderBy != nil {
 		orderByParts := make([]string, 0, len(selectStmt.OrderBy))
 		for _, order := range selectStmt.OrderBy {
 			orderStr := ""
+			aliased := false
 			switch c := order.Expr.(type) {
 			case *sqlparser.ColName:
-				if alias, ok := aliasMap[c.Name.String()]; ok {
-					orderStr = alias
+				if alias, ok := aliasMap[fieldAlias(mapper.fieldPath(c))]; ok {
+					orderStr, aliased = alias, true
 				} else {
 					orderStr = mapper.mapExpr(order.Expr)
 				}
 			case *sqlparser.FuncExpr:
 				if alias, ok := aliasMap[c.Name.String()]; ok {
-					orderStr = alias
+					orderStr, aliased = alias, true
 				} else {
 					orderStr = mapper.mapExpr(order.Expr)
 				}
 			default:
 				orderStr = mapper.mapExpr(order.Expr)
 			}
+			if _, ok := selected[orderStr]; distinct && !aliased && !ok && !starSelects(sourceExprs, order.Expr) {
+				mapper.fail(fmt.Errorf("ORDER BY %s: SELECT DISTINCT can only be ordered by selected columns", sqlparser.String(order.Expr)))
+			}
 
 			if order.Direction != "" {
 				orderStr += " " + order.Direction
@@ -202,91 +226,166 @@ func (mapper *SQLMapper) mapSelectStatement(selectStmt *sqlparser.Select) string
 	return strings.Join(parts, " ")
 }
 
-func (mapper *SQLMapper) mapTableExprWithCondition(tableExpr sqlparser.TableExpr) (string, bool) {
+// mapTableExprWithCondition maps a FROM item. It also returns the topic
+// conditions of its tables that the caller still has to apply: in WHERE for
+// a top-level item, or in the ON clause of an outer join whose
+// null-supplying side holds the tables.
+func (mapper *SQLMapper) mapTableExprWithCondition(tableExpr sqlparser.TableExpr) (string, []string) {
 	switch expr := tableExpr.(type) {
 	case *sqlparser.AliasedTableExpr:
 		return mapper.mapAliasedTableExprWithCondition(expr)
 	case *sqlparser.JoinTableExpr:
-		return mapper.mapJoinTableExpr(expr), true
+		return mapper.mapJoinTableExpr(expr)
 	case *sqlparser.ParenTableExpr:
 		innerParts := make([]string, 0, len(expr.Exprs))
-		hasCondition := false
+		conditions := make([]string, 0)
 		for _, innerExpr := range expr.Exprs {
-			mapped, innerHasCondition := mapper.mapTableExprWithCondition(innerExpr)
+			mapped, innerConditions := mapper.mapTableExprWithCondition(innerExpr)
 			innerParts = append(innerParts, mapped)
-			if innerHasCondition {
-				hasCondition = true
-			}
+			conditions = append(conditions, innerConditions...)
 		}
-		return "(" + strings.Join(innerParts, ", ") + ")", hasCondition
+		return "(" + strings.Join(innerParts, ", ") + ")", conditions
 	default:
-		return sqlparser.String(tableExpr), false
+		return sqlparser.String(tableExpr), nil
 	}
 }
 
-func (mapper *SQLMapper) mapAliasedTableExprWithCondition(table *sqlparser.AliasedTableExpr) (string, bool) {
+func (mapper *SQLMapper) mapAliasedTableExprWithCondition(table *sqlparser.AliasedTableExpr) (string, []string) {
 	switch expr := table.Expr.(type) {
 	case sqlparser.TableName:
 		tableName := expr.Name.String()
-		if tableName != "" && tableName != mapper.TableName {
-			mappedTable := mapper.TableName
+		if !expr.Qualifier.IsEmpty() {
+			qualifier := tableName
+			if !table.As.IsEmpty() {
+				qualifier = table.As.String()
+			}
+			if ref, ok := mapper.scope.tables[qualifier]; !ok || ref.arrayOf != "" {
+				return mapper.mapArrayTableExpr(table, expr)
+			}
+		} else if _, ok := mapper.commonTables[tableName]; ok {
+			mapped := quoteIdent(tableName)
 			if !table.As.IsEmpty() {
-				mappedTable += " AS " + table.As.String()
+				mapped += " AS " + quoteIdent(table.As.String())
 			}
-			return mappedTable, true
+			return mapped, nil
 		}
-		return sqlparser.String(table), false
-	case *sqlparser.Subquery:
-		if selectStmt, ok := expr.Select.(*sqlparser.Select); ok {
-			mappedSubquery := mapper.mapSelectStatement(selectStmt)
-			result := "(" + mappedSubquery + ")"
+		if tableName != "" && tableName != mapper.TableName {
+			// Every topic reference gets an alias, the topic name unless the
+			// query names it, so qualified columns and correlated references
+			// always reach a relation. The topic condition is qualified only
+			// when another table could also supply the payload column.
+			alias, qualifier := tableName, ""
 			if !table.As.IsEmpty() {
-				result += " AS " + table.As.String()
+				alias = table.As.String()
+				qualifier = alias
+			} else if len(mapper.scope.order) > 1 {
+				qualifier = alias
 			}
-			return result, false
+			mappedTable := mapper.TableName + " AS " + quoteIdent(alias)
+			return mappedTable, []string{mapper.topicCondition(qualifier, tableName)}
+		}
+		return sqlparser.String(table), nil
+	case *sqlparser.Subquery:
+		result := "(" + mapper.mapSubqueryStatement(expr.Select) + ")"
+		if !table.As.IsEmpty() {
+			result += " AS " + quoteIdent(table.As.String())
 		}
+		return result, nil
 	}
-	return sqlparser.String(table), false
+	return sqlparser.String(table), nil
 }
 
-func (mapper *SQLMapper) extractTableConditions(tableExprs sqlparser.TableExprs) []string {
-	conditions := make([]string, 0)
-	for _, tableExpr := range tableExprs {
-		mapper.extractConditionsFromTableExpr(tableExpr, &conditions)
+// mapArrayTableExpr expands a parent.field table reference into a LATERAL
+// set of the array's elements. Object elements are exposed under the payload
+// column name so their fields map like top-level ones; scalar elements are
+// exposed as text.
+func (mapper *SQLMapper) mapArrayTableExpr(table *sqlparser.AliasedTableExpr, name sqlparser.TableName) (string, []string) {
+	qualifier := name.Name.String()
+	if !table.As.IsEmpty() {
+		qualifier = table.As.String()
+	}
+	ref, ok := mapper.scope.lookup(qualifier)
+	if !ok || ref.arrayOf == "" {
+		return mapper.fail(fmt.Errorf("unknown array table: %s", sqlparser.String(name))), nil
+	}
+
+	fn, col := "jsonb_array_elements", mapper.PayloadCol
+	if ref.scalar {
+		fn, col = "jsonb_array_elements_text", scalarElementCol
 	}
-	return conditions
+	elements := fmt.Sprintf("LATERAL %s(%s.%s -> '%s') AS %s(%s)", fn, quoteIdent(ref.arrayOf), mapper.PayloadCol, name.Name.String(), quoteIdent(qualifier), col)
+	if ref.ownParent {
+		mapped := fmt.Sprintf("%s AS %s CROSS JOIN %s", mapper.TableName, quoteIdent(ref.arrayOf), elements)
+		return mapped, []string{mapper.topicCondition(ref.arrayOf, ref.arrayOf)}
+	}
+	return elements, nil
 }
 
-func (mapper *SQLMapper) extractConditionsFromTableExpr(tableExpr sqlparser.TableExpr, conditions *[]string) {
-	switch expr := tableExpr.(type) {
-	case *sqlparser.AliasedTableExpr:
-		switch table := expr.Expr.(type) {
-		case sqlparser.TableName:
-			tableName := table.Name.String()
-			if tableName != "" && tableName != mapper.TableName {
-				condition := fmt.Sprintf("(%s ->> '%s') = '%s'", mapper.PayloadCol, mapper.Topic, tableName)
-				if !expr.As.IsEmpty() {
-					condition = fmt.Sprintf("(%s.%s ->> '%s') = '%s'", expr.As.String(), mapper.PayloadCol, mapper.Topic, tableName)
-				}
-				*conditions = append(*conditions, condition)
-			}
+// topicCondition restricts the rows reached through qualifier to a topic.
+func (mapper *SQLMapper) topicCondition(qualifier, topic string) string {
+	return fmt.Sprintf("%s = '%s'", mapper.Topic.Expr(quoteIdent(qualifier), mapper.PayloadCol), topic)
+}
+
+// mapJoinTableExpr maps a join. USING and NATURAL joins become ON
+// conditions on payload fields, and an inner join left without a condition
+// is emitted as a CROSS JOIN. The topic conditions of an outer join's
+// null-supplying side go into its ON clause, since filtering those rows in
+// WHERE would drop the unmatched rows and turn it into an inner join; the
+// preserved side's conditions, and both sides' of an inner join, are
+// returned to the caller.
+func (mapper *SQLMapper) mapJoinTableExpr(join *sqlparser.JoinTableExpr) (string, []string) {
+	leftTable, leftConditions := mapper.mapTableExprWithCondition(join.LeftExpr)
+	rightTable, rightConditions := mapper.mapTableExprWithCondition(join.RightExpr)
+	if mapper.addsParent(join.RightExpr) {
+		rightTable = "(" + rightTable + ")"
+	}
+
+	onConditions := make([]string, 0)
+	switch {
+	case join.Condition.On != nil:
+		on := mapper.mapExpr(join.Condition.On)
+		if _, ok := join.Condition.On.(*sqlparser.OrExpr); ok {
+			on = "(" + on + ")"
 		}
-	case *sqlparser.JoinTableExpr:
-		mapper.extractConditionsFromTableExpr(expr.LeftExpr, conditions)
-		mapper.extractConditionsFromTableExpr(expr.RightExpr, conditions)
-	case *sqlparser.ParenTableExpr:
-		for _, innerExpr := range expr.Exprs {
-			mapper.extractConditionsFromTableExpr(innerExpr, conditions)
+		onConditions = append(onConditions, on)
+	case len(join.Condition.Using) > 0:
+		fields := make([]string, 0, len(join.Condition.Using))
+		for _, col := range join.Condition.Using {
+			fields = append(fields, col.String())
+		}
+		onConditions = append(onConditions, mapper.usingConditions(join, fields)...)
+	case isNaturalJoin(join.Join):
+		fields, err := mapper.naturalFields(join)
+		if err != nil {
+			mapper.fail(err)
 		}
+		onConditions = append(onConditions, mapper.usingConditions(join, fields)...)
 	}
-}
 
-func (mapper *SQLMapper) mapJoinTableExpr(join *sqlparser.JoinTableExpr) string {
-	leftTable, _ := mapper.mapTableExprWithCondition(join.LeftExpr)
-	rightTable, _ := mapper.mapTableExprWithCondition(join.RightExpr)
-	condition := mapper.mapExpr(join.Condition.On)
+	joinType, ok := joinTypes[join.Join]
+	if !ok {
+		joinType = join.Join
+	}
 
-	return fmt.Sprintf("%s %s %s ON %s", leftTable, join.Join, rightTable, condition)
+	var conditions []string
+	switch joinType {
+	case sqlparser.LeftJoinStr:
+		onConditions = append(onConditions, rightConditions...)
+		conditions = leftConditions
+	case sqlparser.RightJoinStr:
+		onConditions = append(onConditions, leftConditions...)
+		conditions = rightConditions
+	default:
+		conditions = append(leftConditions, rightConditions...)
+	}
+
+	if len(onConditions) == 0 {
+		if joinType == sqlparser.JoinStr {
+			return fmt.Sprintf("%s cross join %s", leftTable, rightTable), conditions
+		}
+		onConditions = append(onConditions, "TRUE")
+	}
+	return fmt.Sprintf("%s %s %s ON %s", leftTable, joinType, rightTable, strings.Join(onConditions, " AND ")), conditions
 }
 
 func (mapper *SQLMapper) mapSelectExpr(expr sqlparser.SelectExpr) string {
@@ -300,31 +399,25 @@ func (mapper *SQLMapper) mapSelectExpr(expr sqlparser.SelectExpr) string {
 			} else {
 				alias = fn.Name.String()
 			}
-			return fmt.Sprintf("%s AS %s", mapped, alias)
+			return fmt.Sprintf("%s AS %s", mapped, quoteIdent(alias))
 		}
 
 		if col, ok := se.Expr.(*sqlparser.ColName); ok {
-			colName := col.Name.String()
-			var alias string
+			alias := fieldAlias(mapper.fieldPath(col))
 			if !se.As.IsEmpty() {
 				alias = se.As.String()
-			} else if col.Qualifier.Name.String() != "" {
-				alias = col.Qualifier.Name.String()
-			} else {
-				alias = colName
 			}
-			return fmt.Sprintf("(%s ->> '%s') AS %s", mapper.PayloadCol, colName, alias)
+			return fmt.Sprintf("%s AS %s", mapper.mapExpr(col), quoteIdent(alias))
 		}
 
 		mapped := mapper.mapExpr(se.Expr)
-		alias := se.As.String()
-		if alias == "" {
-			alias = mapped
+		if se.As.IsEmpty() {
+			return mapped
 		}
-		return fmt.Sprintf("%s AS %s", mapped, alias)
+		return fmt.Sprintf("%s AS %s", mapped, quoteIdent(se.As.String()))
 
 	case *sqlparser.StarExpr:
-		return "*"
+		return mapper.expandStar(se)
 	default:
 		return sqlparser.String(expr)
 	}
@@ -337,18 +430,7 @@ func (mapper *SQLMapper) mapExpr(expr sqlparser.Expr) string {
 
 	switch e := expr.(type) {
 	case *sqlparser.ColName:
-		columnName := e.Name.String()
-		mapped := fmt.Sprintf("(%s ->> '%s')", mapper.PayloadCol, columnName)
-		if _, ok := mapper.NumericFields[columnName]; ok {
-			mapped += "::FLOAT"
-		}
-		if e.Qualifier.Name.String() != "" {
-			mapped = fmt.Sprintf("(%s.%s ->> '%s')", e.Qualifier.Name.String(), mapper.PayloadCol, columnName)
-			if _, ok := mapper.NumericFields[columnName]; ok {
-				mapped += "::FLOAT"
-			}
-		}
-		return mapped
+		return mapper.columnAccess(e) + mapper.fieldCast(e)
 
 	case *sqlparser.SQLVal:
 		switch e.Type {
@@ -362,28 +444,41 @@ func (mapper *SQLMapper) mapExpr(expr sqlparser.Expr) string {
 	case *sqlparser.ParenExpr:
 		return "(" + mapper.mapExpr(e.Expr) + ")"
 	case *sqlparser.ComparisonExpr:
+		if e.Operator == sqlparser.InStr || e.Operator == sqlparser.NotInStr {
+			return mapper.mapInExpr(e)
+		}
+		if isPatternOperator(e.Operator) {
+			return mapper.mapPatternExpr(e)
+		}
+		if e.Operator == sqlparser.NullSafeEqualStr {
+			return fmt.Sprintf("%s IS NOT DISTINCT FROM %s", mapper.mapExpr(e.Left), mapper.mapExpr(e.Right))
+		}
 		return fmt.Sprintf("%s %s %s", mapper.mapExpr(e.Left), e.Operator, mapper.mapExpr(e.Right))
+	case *sqlparser.Subquery:
+		return "(" + mapper.mapSubqueryStatement(e.Select) + ")"
+	case *sqlparser.ConvertExpr:
+		return mapper.mapConvertExpr(e)
+	case *sqlparser.ConvertUsingExpr:
+		// PostgreSQL databases hold a single encoding; USING is dropped.
+		return mapper.mapExpr(e.Expr)
+	case *sqlparser.CaseExpr:
+		return mapper.mapCaseExpr(e)
+	case *sqlparser.ExistsExpr:
+		return "EXISTS (" + mapper.mapSubqueryStatement(e.Subquery.Select) + ")"
 	case *sqlparser.IsExpr:
-		left := mapper.mapExpr(e.Expr)
-		if e.Operator == "is not null" {
-			return fmt.Sprintf("%s IS NOT NULL", left)
-		}
-		return fmt.Sprintf("%s IS %s", left, e.Operator)
+		return mapper.mapIsExpr(e)
+	case *sqlparser.NotExpr:
+		return "NOT " + mapper.mapBoolOperand(e.Expr)
 	case *sqlparser.FuncExpr:
-		args := make([]string, 0, len(e.Exprs))
-		for _, expr := range e.Exprs {
-			switch ae := expr.(type) {
-			case *sqlparser.AliasedExpr:
-				args = append(args, mapper.mapExpr(ae.Expr))
-			case *sqlparser.StarExpr:
-				args = append(args, "*")
-			default:
-				args = append(args, mapper.mapExpr(ae.(sqlparser.Expr)))
-			}
-		}
-		return fmt.Sprintf("%s(%s)", e.Name.String(), strings.Join(args, ", "))
+		return mapper.mapFuncExpr(e)
+	case *sqlparser.GroupConcatExpr:
+		return mapper.mapGroupConcatExpr(e)
+	case *sqlparser.SubstrExpr:
+		return mapper.mapSubstrExpr(e)
+	case *sqlparser.IntervalExpr:
+		return mapper.mapIntervalExpr(e)
 	case *sqlparser.OrExpr:
-		return fmt.Sprintf("%s OR %s", mapper.mapExpr(e.Left), mapper.mapExpr(e.Right))
+		return mapper.mapOrExpr(e)
 	case *sqlparser.AndExpr:
 		return fmt.Sprintf("%s AND %s", mapper.mapExpr(e.Left), mapper.mapExpr(e.Right))
 	case *sqlparser.RangeCond:
@@ -395,18 +490,75 @@ func (mapper *SQLMapper) mapExpr(expr sqlparser.Expr) string {
 	}
 }
 
-func (mapper *SQLMapper) mapUnionStatement(union *sqlparser.Union) string {
-	left := mapper.mapSelectStatement(union.Left.(*sqlparser.Select))
-	right := mapper.mapSelectStatement(union.Right.(*sqlparser.Select))
-	parts := []string{left, "UNION"}
-	if strings.Contains(strings.ToUpper(sqlparser.String(union)), "UNION ALL") {
-		parts = append(parts, "ALL")
+// mapSubqueryStatement maps a nested SELECT, UNION or parenthesized SELECT.
+// Its tables get their own scope whose parent is the enclosing query's.
+func (mapper *SQLMapper) mapSubqueryStatement(stmt sqlparser.SelectStatement) string {
+	switch s := stmt.(type) {
+	case *sqlparser.Select:
+		return mapper.mapSelectStatement(s)
+	case *sqlparser.Union:
+		return mapper.mapUnionStatement(s)
+	case *sqlparser.ParenSelect:
+		return "(" + mapper.mapSubqueryStatement(s.Select) + ")"
+	default:
+		return sqlparser.String(stmt)
+	}
+}
+
+// mapInExpr maps IN / NOT IN. Value lists are mapped element by element with
+// literals coerced to the left column's type; subqueries are mapped
+// recursively, including the topic filter of their own FROM.
+func (mapper *SQLMapper) mapInExpr(e *sqlparser.ComparisonExpr) string {
+	left := mapper.mapExpr(e.Left)
+	cast := ""
+	if col, ok := e.Left.(*sqlparser.ColName); ok {
+		cast = mapper.fieldCast(col)
+	}
+
+	right := ""
+	switch r := e.Right.(type) {
+	case sqlparser.ValTuple:
+		items := make([]string, 0, len(r))
+		for _, item := range r {
+			items = append(items, mapper.mapLiteral(item, cast))
+		}
+		right = "(" + strings.Join(items, ", ") + ")"
+	default:
+		right = mapper.mapExpr(e.Right)
 	}
-	parts = append(parts, right)
+	return fmt.Sprintf("%s %s %s", left, strings.ToUpper(e.Operator), right)
+}
+
+// mapLiteral maps a value compared against a column with the given cast:
+// strings take the cast, and numbers compared with a text column are quoted.
+func (mapper *SQLMapper) mapLiteral(expr sqlparser.Expr, cast string) string {
+	val, ok := expr.(*sqlparser.SQLVal)
+	if !ok {
+		return mapper.mapExpr(expr)
+	}
+	switch {
+	case val.Type == sqlparser.StrVal && cast != "":
+		return mapper.mapExpr(val) + cast
+	case (val.Type == sqlparser.IntVal || val.Type == sqlparser.FloatVal) && cast == "":
+		return "'" + string(val.Val) + "'"
+	default:
+		return mapper.mapExpr(val)
+	}
+}
+
+func (mapper *SQLMapper) mapUnionStatement(union *sqlparser.Union) string {
+	left := mapper.mapSubqueryStatement(union.Left)
+	right := mapper.mapSubqueryStatement(union.Right)
+	parts := []string{left, strings.ToUpper(union.Type), right}
 	if union.OrderBy != nil {
 		orderByParts := make([]string, 0, len(union.OrderBy))
 		for _, order := range union.OrderBy {
+			// The ORDER BY of a UNION sorts its result, whose columns are
+			// the output names of the first SELECT.
 			orderStr := mapper.mapExpr(order.Expr)
+			if col, ok := order.Expr.(*sqlparser.ColName); ok {
+				orderStr = quoteIdent(strings.ReplaceAll(col.Name.String(), ".", nestedSeparator))
+			}
 			if order.Direction != "" {
 				orderStr += " " + order.Direction
 			}
@@ -0,0 +1,222 @@
+package converter
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/xwb1989/sqlparser"
+)
+
+// intervalUnits maps MySQL interval units to one PostgreSQL interval step.
+var intervalUnits = map[string]string{
+	"microsecond": "1 microsecond",
+	"second":      "1 second",
+	"minute":      "1 minute",
+	"hour":        "1 hour",
+	"day":         "1 day",
+	"week":        "1 week",
+	"month":       "1 month",
+	"quarter":     "3 month",
+	"year":        "1 year",
+}
+
+// dateFormatSpecifiers maps MySQL DATE_FORMAT specifiers to to_char patterns.
+var dateFormatSpecifiers = map[byte]string{
+	'Y': "YYYY",
+	'y': "YY",
+	'm': "MM",
+	'c': "FMMM",
+	'd': "DD",
+	'e': "FMDD",
+	'D': "FMDDth",
+	'j': "DDD",
+	'H': "HH24",
+	'k': "FMHH24",
+	'h': "HH12",
+	'I': "HH12",
+	'l': "FMHH12",
+	'i': "MI",
+	's': "SS",
+	'S': "SS",
+	'f': "US",
+	'p': "AM",
+	'M': "FMMonth",
+	'b': "Mon",
+	'W': "FMDay",
+	'a': "Dy",
+	'v': "IW",
+	'x': "IYYY",
+	'T': "HH24:MI:SS",
+	'r': "HH12:MI:SS AM",
+	'%': "%",
+}
+
+// temporal returns argument i as a timestamp. Date and timestamp fields keep
+// their catalog cast; other fields and string constants are read as
+// TIMESTAMPTZ.
+func (c *funcCall) temporal(i int) string {
+	switch e := c.exprs[i].(type) {
+	case *sqlparser.ColName:
+		if cast := c.mapper.fieldCast(e); cast == "::TIMESTAMPTZ" || cast == "::DATE" {
+			return c.args[i]
+		}
+		return c.mapper.columnAccess(e) + "::TIMESTAMPTZ"
+	case *sqlparser.SQLVal:
+		if e.Type == sqlparser.StrVal {
+			return c.args[i] + "::TIMESTAMPTZ"
+		}
+	}
+	return c.term(i)
+}
+
+// keyword maps a niladic date function to its PostgreSQL spelling. MySQL's
+// optional fractional seconds precision is ignored.
+func keyword(sql string) func(c *funcCall) (string, error) {
+	return func(c *funcCall) (string, error) {
+		return sql, nil
+	}
+}
+
+func castFunc(cast string) func(c *funcCall) (string, error) {
+	return func(c *funcCall) (string, error) {
+		return c.temporal(0) + cast, nil
+	}
+}
+
+// extractFunc maps YEAR(d), MONTH(d) and friends to EXTRACT, adjusted by
+// offset where MySQL numbers differently (DAYOFWEEK counts from 1 on Sunday,
+// WEEKDAY from 0 on Monday).
+func extractFunc(field, offset string) func(c *funcCall) (string, error) {
+	return func(c *funcCall) (string, error) {
+		extract := fmt.Sprintf("EXTRACT(%s FROM %s)::INT", field, c.temporal(0))
+		if offset == "" {
+			return extract, nil
+		}
+		return "(" + extract + offset + ")", nil
+	}
+}
+
+func dateDiffFunc(c *funcCall) (string, error) {
+	return fmt.Sprintf("(%s::DATE - %s::DATE)", c.temporal(0), c.temporal(1)), nil
+}
+
+// dateArithFunc maps DATE_ADD/DATE_SUB and ADDDATE/SUBDATE. A plain number
+// instead of an INTERVAL counts days.
+func dateArithFunc(op string) func(c *funcCall) (string, error) {
+	return func(c *funcCall) (string, error) {
+		interval := c.args[1]
+		if _, ok := c.exprs[1].(*sqlparser.IntervalExpr); !ok {
+			interval = c.term(1) + " * INTERVAL '1 day'"
+		}
+		return fmt.Sprintf("(%s %s %s)", c.temporal(0), op, interval), nil
+	}
+}
+
+func lastDayFunc(c *funcCall) (string, error) {
+	return fmt.Sprintf("(date_trunc('month', %s) + INTERVAL '1 month - 1 day')::DATE", c.temporal(0)), nil
+}
+
+func dateFormatFunc(c *funcCall) (string, error) {
+	format, err := c.dateFormat(1)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("to_char(%s, %s)", c.temporal(0), format), nil
+}
+
+func strToDateFunc(c *funcCall) (string, error) {
+	format, err := c.dateFormat(1)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("to_timestamp(%s, %s)", c.text(0), format), nil
+}
+
+func unixTimestampFunc(c *funcCall) (string, error) {
+	if len(c.args) == 0 {
+		return "EXTRACT(EPOCH FROM now())::BIGINT", nil
+	}
+	return fmt.Sprintf("EXTRACT(EPOCH FROM %s)::BIGINT", c.temporal(0)), nil
+}
+
+func fromUnixtimeFunc(c *funcCall) (string, error) {
+	ts := "to_timestamp(" + c.args[0] + ")"
+	if len(c.args) == 1 {
+		return ts, nil
+	}
+	format, err := c.dateFormat(1)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("to_char(%s, %s)", ts, format), nil
+}
+
+// dateFormat converts the MySQL format string in argument i into a quoted
+// to_char pattern. Literal text is double-quoted so that to_char does not
+// read it as a pattern.
+func (c *funcCall) dateFormat(i int) (string, error) {
+	format, ok := c.strLiteral(i)
+	if !ok {
+		return "", fmt.Errorf("format must be a string constant")
+	}
+
+	var b, literal strings.Builder
+	flush := func() {
+		if literal.Len() == 0 {
+			return
+		}
+		text := literal.String()
+		if strings.IndexFunc(text, isPatternRune) >= 0 {
+			text = `"` + strings.ReplaceAll(text, `"`, `\"`) + `"`
+		}
+		b.WriteString(text)
+		literal.Reset()
+	}
+	for j := 0; j < len(format); j++ {
+		if format[j] != '%' || j+1 == len(format) {
+			literal.WriteByte(format[j])
+			continue
+		}
+		j++
+		pattern, ok := dateFormatSpecifiers[format[j]]
+		if !ok {
+			return "", fmt.Errorf("format specifier not supported: %%%c", format[j])
+		}
+		flush()
+		b.WriteString(pattern)
+	}
+	flush()
+	return "'" + strings.ReplaceAll(b.String(), "'", "''") + "'", nil
+}
+
+// isPatternRune reports whether to_char could read r as part of a pattern.
+func isPatternRune(r rune) bool {
+	return r > 127 || r == '"' || r == '\\' ||
+		(r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9')
+}
+
+// mapIntervalExpr maps INTERVAL n unit. A constant becomes an interval
+// literal; any other expression scales one unit step.
+func (mapper *SQLMapper) mapIntervalExpr(e *sqlparser.IntervalExpr) string {
+	step, ok := intervalUnits[strings.ToLower(e.Unit)]
+	if !ok {
+		return mapper.fail(fmt.Errorf("interval unit not supported: %s", e.Unit))
+	}
+	if val, ok := e.Expr.(*sqlparser.SQLVal); ok && (val.Type == sqlparser.IntVal || val.Type == sqlparser.StrVal) && !strings.HasPrefix(step, "3 ") {
+		return fmt.Sprintf("INTERVAL '%s %s'", val.Val, strings.TrimPrefix(step, "1 "))
+	}
+	operand := mapper.mapExpr(e.Expr)
+	if _, ok := e.Expr.(*sqlparser.ColName); !ok {
+		operand = "(" + operand + ")"
+	}
+	return fmt.Sprintf("%s * INTERVAL '%s'", operand, step)
+}
+
+// mapSubstrExpr maps SUBSTRING(col, pos[, len]) and its FROM ... FOR form.
+func (mapper *SQLMapper) mapSubstrExpr(e *sqlparser.SubstrExpr) string {
+	args := []string{mapper.columnAccess(e.Name), mapper.mapExpr(e.From)}
+	if e.To != nil {
+		args = append(args, mapper.mapExpr(e.To))
+	}
+	return "substr(" + strings.Join(args, ", ") + ")"
+}
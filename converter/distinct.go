@@ -0,0 +1,63 @@
+package converter
+
+import (
+	"strings"
+
+	"github.com/xwb1989/sqlparser"
+)
+
+// distinctOnExpr returns the DISTINCT ON marker call that normalizeSQL puts
+// first in the select list, or nil.
+func distinctOnExpr(exprs sqlparser.SelectExprs) *sqlparser.FuncExpr {
+	if len(exprs) == 0 {
+		return nil
+	}
+	ae, ok := exprs[0].(*sqlparser.AliasedExpr)
+	if !ok {
+		return nil
+	}
+	fn, ok := ae.Expr.(*sqlparser.FuncExpr)
+	if !ok || !fn.Qualifier.IsEmpty() || fn.Name.String() != distinctOnMarker {
+		return nil
+	}
+	return fn
+}
+
+// mapDistinctOn maps the DISTINCT ON expressions. Like ORDER BY items, a
+// column named in the select list refers to its output column.
+func (mapper *SQLMapper) mapDistinctOn(fn *sqlparser.FuncExpr, aliasMap map[string]string) string {
+	parts := make([]string, 0, len(fn.Exprs))
+	for _, expr := range fn.Exprs {
+		ae, ok := expr.(*sqlparser.AliasedExpr)
+		if !ok {
+			continue
+		}
+		if col, ok := ae.Expr.(*sqlparser.ColName); ok {
+			if alias, ok := aliasMap[fieldAlias(mapper.fieldPath(col))]; ok {
+				parts = append(parts, alias)
+				continue
+			}
+		}
+		parts = append(parts, mapper.mapExpr(ae.Expr))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// starSelects reports whether a * in the select list covers the column, so
+// that ordering a SELECT DISTINCT * by it is valid.
+func starSelects(exprs sqlparser.SelectExprs, expr sqlparser.Expr) bool {
+	col, ok := expr.(*sqlparser.ColName)
+	if !ok {
+		return false
+	}
+	for _, se := range exprs {
+		star, ok := se.(*sqlparser.StarExpr)
+		if !ok {
+			continue
+		}
+		if star.TableName.IsEmpty() || star.TableName.Name == col.Qualifier.Name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,90 @@
+package converter
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/xwb1989/sqlparser"
+
+	"sqlalchemy/db"
+)
+
+// nestedSeparator is the identifier-safe alternative to a backquoted dotted
+// name: device__location__site and `device.location.site` address the same
+// nested field.
+const nestedSeparator = "__"
+
+// scalarElementCol names the column holding the elements of a scalar array,
+// matching the field name the catalog uses for them.
+const scalarElementCol = db.ArrayElementField
+
+// fieldPath splits a column reference into the JSON path it addresses. A name
+// the catalog already knows verbatim is never split on the separator.
+func (mapper *SQLMapper) fieldPath(col *sqlparser.ColName) []string {
+	name := col.Name.String()
+	if strings.Contains(name, ".") {
+		return strings.Split(name, ".")
+	}
+	if strings.Contains(name, nestedSeparator) && !mapper.hasField(col, name) {
+		return strings.Split(name, nestedSeparator)
+	}
+	return []string{name}
+}
+
+func (mapper *SQLMapper) hasField(col *sqlparser.ColName, field string) bool {
+	if mapper.Catalog == nil {
+		return false
+	}
+	for _, topic := range mapper.columnTopics(col) {
+		if _, ok := mapper.Catalog.FieldType(topic, field); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// columnAccess renders the text a column reference reads: a path inside the
+// payload of its table, the element itself for an array of scalars, or a
+// real column found by boundColumn.
+func (mapper *SQLMapper) columnAccess(col *sqlparser.ColName) string {
+	if bound, ok := mapper.boundColumn(col); ok {
+		return bound
+	}
+	qualifier := col.Qualifier.Name.String()
+	if qualifier == "" {
+		qualifier = mapper.resolveQualifier(col)
+	}
+	if ref, ok := mapper.scope.lookup(qualifier); ok && ref.scalar {
+		return quoteIdent(qualifier) + "." + scalarElementCol
+	}
+	return mapper.fieldAccess(qualifier, mapper.fieldPath(col))
+}
+
+// fieldNames lists the catalog names an unqualified column may refer to: the
+// name as written and, for separator-style names, its dotted form.
+func fieldNames(col *sqlparser.ColName) []string {
+	name := col.Name.String()
+	names := []string{name}
+	if strings.Contains(name, nestedSeparator) {
+		names = append(names, strings.ReplaceAll(name, nestedSeparator, "."))
+	}
+	return names
+}
+
+// fieldAccess extracts a JSON path from the payload column as text.
+func (mapper *SQLMapper) fieldAccess(qualifier string, path []string) string {
+	payload := mapper.PayloadCol
+	if qualifier != "" {
+		payload = quoteIdent(qualifier) + "." + payload
+	}
+	if len(path) == 1 {
+		return fmt.Sprintf("(%s ->> '%s')", payload, path[0])
+	}
+	return fmt.Sprintf("(%s #>> '{%s}')", payload, strings.Join(path, ","))
+}
+
+// fieldAlias names the output column of a JSON path; nested paths use the
+// separator so the alias stays a plain identifier.
+func fieldAlias(path []string) string {
+	return strings.Join(path, nestedSeparator)
+}
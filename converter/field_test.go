@@ -0,0 +1,52 @@
+package converter
+
+import (
+	"testing"
+
+	"sqlalchemy/db"
+)
+
+var fieldCatalog = db.StaticCatalog{
+	"pump_alarm": {"device.location.site": db.FieldInteger, "code": db.FieldText},
+}
+
+// TestNestedFieldOutputNames checks that ORDER BY, GROUP BY and DISTINCT ON
+// refer to a nested field by the output column the select list gives it.
+func TestNestedFieldOutputNames(t *testing.T) {
+	tests := []struct {
+		name string
+		sql  string
+		want string
+	}{
+		{
+			name: "order by dotted name",
+			sql:  "SELECT `device.location.site` FROM pump_alarm ORDER BY `device.location.site`",
+			want: "SELECT (payload #>> '{device,location,site}')::BIGINT AS device__location__site " +
+				"FROM tsdb_table AS pump_alarm WHERE (payload ->> 'topic') = 'pump_alarm' ORDER BY device__location__site asc",
+		},
+		{
+			name: "group by separator name",
+			sql:  "SELECT device__location__site, COUNT(*) AS n FROM pump_alarm GROUP BY device__location__site",
+			want: "SELECT (payload #>> '{device,location,site}')::BIGINT AS device__location__site, COUNT(*) AS n " +
+				"FROM tsdb_table AS pump_alarm WHERE (payload ->> 'topic') = 'pump_alarm' GROUP BY device__location__site",
+		},
+		{
+			name: "distinct on dotted name",
+			sql:  "SELECT DISTINCT ON (`device.location.site`) `device.location.site`, code FROM pump_alarm",
+			want: "SELECT DISTINCT ON (device__location__site) (payload #>> '{device,location,site}')::BIGINT AS device__location__site, (payload ->> 'code') AS code " +
+				"FROM tsdb_table AS pump_alarm WHERE (payload ->> 'topic') = 'pump_alarm'",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mapper, err := NewSQLMapper(tt.sql, fieldCatalog, "tsdb_table", "payload", db.TopicKey{Name: "topic"}, nil)
+			if err != nil {
+				t.Fatalf("NewSQLMapper: %v", err)
+			}
+			if mapper.MappedSQL != tt.want {
+				t.Errorf("mapped SQL\n got: %s\nwant: %s", mapper.MappedSQL, tt.want)
+			}
+		})
+	}
+}
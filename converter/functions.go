@@ -0,0 +1,422 @@
+package converter
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/xwb1989/sqlparser"
+)
+
+// funcCall is a function call being translated: its name as written, the
+// argument expressions and their mapped SQL. A * argument has a nil
+// expression.
+type funcCall struct {
+	mapper   *SQLMapper
+	name     string
+	distinct bool
+	exprs    []sqlparser.Expr
+	args     []string
+}
+
+// sqlFunc translates one MySQL function into PostgreSQL. maxArgs < 0 means
+// the function is variadic.
+type sqlFunc struct {
+	minArgs int
+	maxArgs int
+	rewrite func(c *funcCall) (string, error)
+}
+
+// mysqlFunctions is the built-in MySQL to PostgreSQL function table, keyed by
+// lower-case name. Functions missing from it are rejected. It is filled in
+// init because IF maps through mapExpr, which reads the table.
+var mysqlFunctions map[string]sqlFunc
+
+func init() {
+	mysqlFunctions = map[string]sqlFunc{
+		// aggregate
+		"count":       {1, -1, countFunc},
+		"sum":         {1, 1, same},
+		"avg":         {1, 1, same},
+		"min":         {1, 1, same},
+		"max":         {1, 1, same},
+		"bit_and":     {1, 1, same},
+		"bit_or":      {1, 1, same},
+		"std":         {1, 1, rename("stddev_pop")},
+		"stddev":      {1, 1, rename("stddev_pop")},
+		"stddev_pop":  {1, 1, same},
+		"stddev_samp": {1, 1, same},
+		"variance":    {1, 1, rename("var_pop")},
+		"var_pop":     {1, 1, same},
+		"var_samp":    {1, 1, same},
+
+		// control flow
+		"coalesce": {1, -1, same},
+		"nullif":   {2, 2, same},
+		"greatest": {1, -1, same},
+		"least":    {1, -1, same},
+		"ifnull":   {2, 2, rename("coalesce")},
+		"if":       {3, 3, ifFunc},
+
+		// math
+		"abs":      {1, 1, same},
+		"ceil":     {1, 1, same},
+		"ceiling":  {1, 1, same},
+		"floor":    {1, 1, same},
+		"round":    {1, 2, roundFunc("round")},
+		"truncate": {2, 2, roundFunc("trunc")},
+		"sqrt":     {1, 1, same},
+		"exp":      {1, 1, same},
+		"ln":       {1, 1, same},
+		"log":      {1, 2, logFunc},
+		"log10":    {1, 1, same},
+		"log2":     {1, 1, log2Func},
+		"power":    {2, 2, same},
+		"pow":      {2, 2, rename("power")},
+		"sign":     {1, 1, same},
+		"mod":      {2, 2, same},
+		"pi":       {0, 0, same},
+		"degrees":  {1, 1, same},
+		"radians":  {1, 1, same},
+		"sin":      {1, 1, same},
+		"cos":      {1, 1, same},
+		"tan":      {1, 1, same},
+		"cot":      {1, 1, same},
+		"asin":     {1, 1, same},
+		"acos":     {1, 1, same},
+		"atan":     {1, 2, atanFunc},
+		"atan2":    {2, 2, same},
+		"rand":     {0, 0, rename("random")},
+
+		// string
+		"concat":           {1, -1, concatFunc},
+		"concat_ws":        {2, -1, same},
+		"upper":            {1, 1, same},
+		"ucase":            {1, 1, rename("upper")},
+		"lower":            {1, 1, same},
+		"lcase":            {1, 1, rename("lower")},
+		"length":           {1, 1, rename("octet_length")},
+		"octet_length":     {1, 1, same},
+		"bit_length":       {1, 1, same},
+		"char_length":      {1, 1, same},
+		"character_length": {1, 1, same},
+		"trim":             {1, 1, same},
+		"ltrim":            {1, 1, same},
+		"rtrim":            {1, 1, same},
+		"replace":          {3, 3, same},
+		"reverse":          {1, 1, same},
+		"repeat":           {2, 2, same},
+		"lpad":             {3, 3, same},
+		"rpad":             {3, 3, same},
+		"left":             {2, 2, same},
+		"right":            {2, 2, same},
+		"ascii":            {1, 1, same},
+		"md5":              {1, 1, same},
+		"mid":              {3, 3, rename("substr")},
+		"space":            {1, 1, spaceFunc},
+		"instr":            {2, 2, instrFunc},
+		"locate":           {2, 3, locateFunc},
+		"substring_index":  {3, 3, substringIndexFunc},
+		"find_in_set":      {2, 2, findInSetFunc},
+		"uuid":             {0, 0, rename("gen_random_uuid")},
+
+		// date and time
+		"now":               {0, 1, keyword("now()")},
+		"sysdate":           {0, 1, keyword("clock_timestamp()")},
+		"current_timestamp": {0, 1, keyword("CURRENT_TIMESTAMP")},
+		"localtime":         {0, 1, keyword("LOCALTIMESTAMP")},
+		"localtimestamp":    {0, 1, keyword("LOCALTIMESTAMP")},
+		"curdate":           {0, 0, keyword("CURRENT_DATE")},
+		"current_date":      {0, 0, keyword("CURRENT_DATE")},
+		"curtime":           {0, 1, keyword("LOCALTIME")},
+		"current_time":      {0, 1, keyword("LOCALTIME")},
+		"utc_timestamp":     {0, 1, keyword("(now() AT TIME ZONE 'UTC')")},
+		"utc_date":          {0, 0, keyword("(now() AT TIME ZONE 'UTC')::DATE")},
+		"utc_time":          {0, 1, keyword("(now() AT TIME ZONE 'UTC')::TIME")},
+		"date":              {1, 1, castFunc("::DATE")},
+		"time":              {1, 1, castFunc("::TIME")},
+		"year":              {1, 1, extractFunc("YEAR", "")},
+		"quarter":           {1, 1, extractFunc("QUARTER", "")},
+		"month":             {1, 1, extractFunc("MONTH", "")},
+		"day":               {1, 1, extractFunc("DAY", "")},
+		"dayofmonth":        {1, 1, extractFunc("DAY", "")},
+		"dayofyear":         {1, 1, extractFunc("DOY", "")},
+		"dayofweek":         {1, 1, extractFunc("DOW", " + 1")},
+		"weekday":           {1, 1, extractFunc("ISODOW", " - 1")},
+		"hour":              {1, 1, extractFunc("HOUR", "")},
+		"minute":            {1, 1, extractFunc("MINUTE", "")},
+		"second":            {1, 1, extractFunc("SECOND", "")},
+		"datediff":          {2, 2, dateDiffFunc},
+		"date_add":          {2, 2, dateArithFunc("+")},
+		"adddate":           {2, 2, dateArithFunc("+")},
+		"date_sub":          {2, 2, dateArithFunc("-")},
+		"subdate":           {2, 2, dateArithFunc("-")},
+		"last_day":          {1, 1, lastDayFunc},
+		"date_format":       {2, 2, dateFormatFunc},
+		"str_to_date":       {2, 2, strToDateFunc},
+		"unix_timestamp":    {0, 1, unixTimestampFunc},
+		"from_unixtime":     {1, 2, fromUnixtimeFunc},
+	}
+}
+
+// aggregateFunctions are the functions that accept DISTINCT.
+var aggregateFunctions = map[string]struct{}{
+	"count": {}, "sum": {}, "avg": {}, "min": {}, "max": {}, "bit_and": {}, "bit_or": {},
+	"std": {}, "stddev": {}, "stddev_pop": {}, "stddev_samp": {},
+	"variance": {}, "var_pop": {}, "var_samp": {},
+}
+
+// mapFuncExpr translates a function call through the user-defined functions
+// and then mysqlFunctions. Calls qualified with a schema name address
+// PostgreSQL functions directly and are passed through.
+func (mapper *SQLMapper) mapFuncExpr(e *sqlparser.FuncExpr) string {
+	if _, ok := aggregateFunctions[e.Name.Lowered()]; ok {
+		mapper.aggregates++
+		defer func() { mapper.aggregates-- }()
+	}
+	c := mapper.newFuncCall(e.Name.String(), e.Exprs)
+	c.distinct = e.Distinct
+
+	if !e.Qualifier.IsEmpty() {
+		return e.Qualifier.String() + "." + c.call(c.name)
+	}
+
+	if c.hasStar() && (e.Name.Lowered() != "count" || len(c.args) != 1 || c.distinct) {
+		return mapper.fail(fmt.Errorf("function %s: * is only allowed in COUNT(*)", c.name))
+	}
+
+	if rewrite, ok := mapper.lookupFunc(e.Name.Lowered()); ok {
+		if c.distinct {
+			return mapper.fail(fmt.Errorf("function %s: DISTINCT not supported", c.name))
+		}
+		mapped, err := rewrite(c.args)
+		if err != nil {
+			return mapper.fail(fmt.Errorf("function %s: %v", c.name, err))
+		}
+		return mapped
+	}
+
+	fn, ok := mysqlFunctions[e.Name.Lowered()]
+	if !ok {
+		return mapper.fail(fmt.Errorf("function not supported: %s", c.name))
+	}
+	if _, ok := aggregateFunctions[e.Name.Lowered()]; c.distinct && !ok {
+		return mapper.fail(fmt.Errorf("function %s: DISTINCT not supported", c.name))
+	}
+	if len(c.args) < fn.minArgs || (fn.maxArgs >= 0 && len(c.args) > fn.maxArgs) {
+		return mapper.fail(fmt.Errorf("function %s: wrong number of arguments: %d", c.name, len(c.args)))
+	}
+	mapped, err := fn.rewrite(c)
+	if err != nil {
+		return mapper.fail(fmt.Errorf("function %s: %v", c.name, err))
+	}
+	return mapped
+}
+
+// newFuncCall maps the arguments of a call.
+func (mapper *SQLMapper) newFuncCall(name string, exprs sqlparser.SelectExprs) *funcCall {
+	c := &funcCall{
+		mapper: mapper,
+		name:   name,
+		exprs:  make([]sqlparser.Expr, 0, len(exprs)),
+		args:   make([]string, 0, len(exprs)),
+	}
+	for _, expr := range exprs {
+		switch ae := expr.(type) {
+		case *sqlparser.AliasedExpr:
+			c.exprs = append(c.exprs, ae.Expr)
+			c.args = append(c.args, mapper.mapExpr(ae.Expr))
+		case *sqlparser.StarExpr:
+			c.exprs = append(c.exprs, nil)
+			c.args = append(c.args, "*")
+		}
+	}
+	return c
+}
+
+// hasStar reports whether an argument is *.
+func (c *funcCall) hasStar() bool {
+	for _, expr := range c.exprs {
+		if expr == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// call formats the call with the mapped arguments under the given name,
+// keeping DISTINCT.
+func (c *funcCall) call(name string) string {
+	distinct := ""
+	if c.distinct {
+		distinct = "DISTINCT "
+	}
+	return name + "(" + distinct + strings.Join(c.args, ", ") + ")"
+}
+
+// term returns argument i in a form a postfix cast or operator can follow.
+func (c *funcCall) term(i int) string {
+	switch c.exprs[i].(type) {
+	case *sqlparser.ColName, *sqlparser.SQLVal, *sqlparser.FuncExpr, *sqlparser.ParenExpr, *sqlparser.ConvertExpr:
+		return c.args[i]
+	default:
+		return "(" + c.args[i] + ")"
+	}
+}
+
+// typed returns argument i cast to the given type. A field operand has its
+// catalog cast replaced rather than stacked.
+func (c *funcCall) typed(i int, cast string) string {
+	if col, ok := c.exprs[i].(*sqlparser.ColName); ok {
+		return c.mapper.columnAccess(col) + cast
+	}
+	if strings.HasSuffix(c.args[i], cast) {
+		return c.args[i]
+	}
+	return c.term(i) + cast
+}
+
+// text returns argument i as text.
+func (c *funcCall) text(i int) string {
+	switch e := c.exprs[i].(type) {
+	case *sqlparser.ColName:
+		return c.mapper.columnAccess(e)
+	case *sqlparser.SQLVal:
+		if e.Type == sqlparser.StrVal {
+			return c.args[i]
+		}
+	}
+	return c.term(i) + "::TEXT"
+}
+
+// intLiteral returns argument i when it is an integer constant.
+func (c *funcCall) intLiteral(i int) (int, bool) {
+	n, err := strconv.Atoi(sqlparser.String(c.exprs[i]))
+	return n, err == nil
+}
+
+// strLiteral returns argument i when it is a string constant.
+func (c *funcCall) strLiteral(i int) (string, bool) {
+	val, ok := c.exprs[i].(*sqlparser.SQLVal)
+	if !ok || val.Type != sqlparser.StrVal {
+		return "", false
+	}
+	return string(val.Val), true
+}
+
+// same keeps the call as written.
+func same(c *funcCall) (string, error) {
+	return c.call(c.name), nil
+}
+
+// rename calls the PostgreSQL function of the given name with the same
+// arguments.
+func rename(name string) func(c *funcCall) (string, error) {
+	return func(c *funcCall) (string, error) {
+		return c.call(name), nil
+	}
+}
+
+// countFunc maps COUNT. MySQL counts distinct combinations of several
+// columns, which PostgreSQL expresses with a row constructor.
+func countFunc(c *funcCall) (string, error) {
+	if len(c.args) == 1 {
+		return c.call(c.name), nil
+	}
+	if !c.distinct {
+		return "", fmt.Errorf("several arguments need DISTINCT")
+	}
+	return c.name + "(DISTINCT (" + strings.Join(c.args, ", ") + "))", nil
+}
+
+func ifFunc(c *funcCall) (string, error) {
+	return c.mapper.mapCaseExpr(&sqlparser.CaseExpr{
+		Whens: []*sqlparser.When{{Cond: c.exprs[0], Val: c.exprs[1]}},
+		Else:  c.exprs[2],
+	}), nil
+}
+
+// roundFunc maps ROUND and TRUNCATE. PostgreSQL only rounds to a number of
+// decimal places on NUMERIC.
+func roundFunc(name string) func(c *funcCall) (string, error) {
+	return func(c *funcCall) (string, error) {
+		if len(c.args) == 1 {
+			return c.call(name), nil
+		}
+		return name + "(" + c.typed(0, "::NUMERIC") + ", " + c.args[1] + ")", nil
+	}
+}
+
+// logFunc maps LOG(x), the natural logarithm in MySQL, and LOG(b, x).
+func logFunc(c *funcCall) (string, error) {
+	if len(c.args) == 1 {
+		return c.call("ln"), nil
+	}
+	return "log(" + c.typed(0, "::NUMERIC") + ", " + c.typed(1, "::NUMERIC") + ")", nil
+}
+
+func log2Func(c *funcCall) (string, error) {
+	return "log(2, " + c.typed(0, "::NUMERIC") + ")", nil
+}
+
+func atanFunc(c *funcCall) (string, error) {
+	if len(c.args) == 1 {
+		return c.call("atan"), nil
+	}
+	return c.call("atan2"), nil
+}
+
+// concatFunc maps CONCAT, which yields NULL when any argument is NULL in
+// MySQL while PostgreSQL's concat skips NULLs.
+func concatFunc(c *funcCall) (string, error) {
+	parts := make([]string, len(c.args))
+	for i := range c.args {
+		parts[i] = c.text(i)
+	}
+	return "(" + strings.Join(parts, " || ") + ")", nil
+}
+
+func spaceFunc(c *funcCall) (string, error) {
+	return "repeat(' ', " + c.args[0] + ")", nil
+}
+
+// instrFunc maps INSTR(str, substr) to strpos, which takes the same order.
+func instrFunc(c *funcCall) (string, error) {
+	return "strpos(" + c.args[0] + ", " + c.args[1] + ")", nil
+}
+
+// locateFunc maps LOCATE(substr, str[, pos]); strpos takes the string first
+// and has no start position.
+func locateFunc(c *funcCall) (string, error) {
+	if len(c.args) == 2 {
+		return "strpos(" + c.args[1] + ", " + c.args[0] + ")", nil
+	}
+	found := fmt.Sprintf("strpos(substr(%s, %s), %s)", c.args[1], c.args[2], c.args[0])
+	return fmt.Sprintf("CASE WHEN %[1]s = 0 THEN 0 ELSE %[1]s + %[2]s - 1 END", found, c.term(2)), nil
+}
+
+// substringIndexFunc maps SUBSTRING_INDEX(str, delim, count) onto an array
+// slice of the split string. The count must be a constant.
+func substringIndexFunc(c *funcCall) (string, error) {
+	count, ok := c.intLiteral(2)
+	if !ok {
+		return "", fmt.Errorf("count must be an integer constant")
+	}
+	parts := fmt.Sprintf("string_to_array(%s, %s)", c.text(0), c.args[1])
+	switch {
+	case count > 0:
+		return fmt.Sprintf("array_to_string((%s)[1:%d], %s)", parts, count, c.args[1]), nil
+	case count == -1:
+		return fmt.Sprintf("array_to_string((%[1]s)[array_length(%[1]s, 1):], %[2]s)", parts, c.args[1]), nil
+	case count < 0:
+		return fmt.Sprintf("array_to_string((%[1]s)[array_length(%[1]s, 1) - %[2]d:], %[3]s)", parts, -count-1, c.args[1]), nil
+	default:
+		return "''", nil
+	}
+}
+
+// findInSetFunc maps FIND_IN_SET(str, list), which returns 0 when str is not
+// an element of the comma separated list.
+func findInSetFunc(c *funcCall) (string, error) {
+	return fmt.Sprintf("COALESCE(array_position(string_to_array(%s, ','), %s), 0)", c.text(1), c.text(0)), nil
+}
@@ -0,0 +1,182 @@
+package converter
+
+import (
+	"fmt"
+
+	"github.com/xwb1989/sqlparser"
+)
+
+// joinTypes maps the join strings sqlparser produces to the PostgreSQL join
+// emitted once NATURAL and USING have been turned into ON conditions.
+var joinTypes = map[string]string{
+	sqlparser.JoinStr:             sqlparser.JoinStr,
+	sqlparser.StraightJoinStr:     sqlparser.JoinStr,
+	sqlparser.LeftJoinStr:         sqlparser.LeftJoinStr,
+	sqlparser.RightJoinStr:        sqlparser.RightJoinStr,
+	sqlparser.NaturalJoinStr:      sqlparser.JoinStr,
+	sqlparser.NaturalLeftJoinStr:  sqlparser.LeftJoinStr,
+	sqlparser.NaturalRightJoinStr: sqlparser.RightJoinStr,
+}
+
+func isNaturalJoin(join string) bool {
+	return join == sqlparser.NaturalJoinStr || join == sqlparser.NaturalLeftJoinStr || join == sqlparser.NaturalRightJoinStr
+}
+
+// usingConditions rewrites USING (f, ...) into equalities between the
+// payload fields of the two sides.
+func (mapper *SQLMapper) usingConditions(join *sqlparser.JoinTableExpr, fields []string) []string {
+	conditions := make([]string, 0, len(fields))
+	for _, field := range fields {
+		left := mapper.sideColumn(join.LeftExpr, field)
+		right := mapper.sideColumn(join.RightExpr, field)
+		// A derived table's column already has a type, which the other
+		// side's catalog cast is more likely to match than JSON text.
+		_, leftBound := mapper.boundColumn(left)
+		_, rightBound := mapper.boundColumn(right)
+		if !leftBound && !rightBound && mapper.fieldCast(left) != mapper.fieldCast(right) {
+			// The sides disagree on the field's type; compare the JSON text.
+			conditions = append(conditions, mapper.columnAccess(left)+" = "+mapper.columnAccess(right))
+			continue
+		}
+		conditions = append(conditions, mapper.mapExpr(left)+" = "+mapper.mapExpr(right))
+	}
+	return conditions
+}
+
+// sideColumn qualifies a join field with the table of one join side that
+// has it, or with the side's last table when the catalog does not tell.
+func (mapper *SQLMapper) sideColumn(side sqlparser.TableExpr, field string) *sqlparser.ColName {
+	qualifiers := tableQualifiers(side)
+	owner := ""
+	if len(qualifiers) > 0 {
+		owner = qualifiers[len(qualifiers)-1]
+	}
+	if mapper.Catalog != nil {
+		for _, q := range qualifiers {
+			if ref, ok := mapper.scope.lookup(q); ok {
+				if _, ok := mapper.Catalog.FieldType(ref.topic, field); ok {
+					owner = q
+					break
+				}
+			}
+		}
+	}
+	return &sqlparser.ColName{
+		Name:      sqlparser.NewColIdent(field),
+		Qualifier: sqlparser.TableName{Name: sqlparser.NewTableIdent(owner)},
+	}
+}
+
+// addJoinedFields records the fields a USING or NATURAL join matches on.
+// Both sides hold the same value, so an unqualified reference to such a
+// field is not ambiguous between them; it reads the left side, or the right
+// side of a RIGHT JOIN, whose rows are always there.
+func (mapper *SQLMapper) addJoinedFields(join *sqlparser.JoinTableExpr) {
+	fields := make([]string, 0, len(join.Condition.Using))
+	switch {
+	case len(join.Condition.Using) > 0:
+		for _, col := range join.Condition.Using {
+			fields = append(fields, col.String())
+		}
+	case isNaturalJoin(join.Join) && mapper.Catalog != nil:
+		fields, _ = mapper.naturalFields(join)
+	}
+
+	for _, field := range fields {
+		left := mapper.sideColumn(join.LeftExpr, field).Qualifier.Name.String()
+		right := mapper.sideColumn(join.RightExpr, field).Qualifier.Name.String()
+		if joinTypes[join.Join] == sqlparser.RightJoinStr {
+			left, right = right, left
+		}
+		if mapper.scope.joined == nil {
+			mapper.scope.joined = make(map[string]map[string]string)
+		}
+		shared, ok := mapper.scope.joined[field]
+		if !ok {
+			shared = make(map[string]string)
+			mapper.scope.joined[field] = shared
+		}
+		// A side may itself be an earlier join on the same field.
+		if target, ok := shared[left]; ok {
+			left = target
+		}
+		if target, ok := shared[right]; ok {
+			right = target
+		}
+		for q, target := range shared {
+			if target == right {
+				shared[q] = left
+			}
+		}
+		shared[left], shared[right] = left, left
+	}
+}
+
+// naturalFields returns the fields a NATURAL join matches on: those the
+// catalog lists for a topic on both sides.
+func (mapper *SQLMapper) naturalFields(join *sqlparser.JoinTableExpr) ([]string, error) {
+	if mapper.Catalog == nil {
+		return nil, fmt.Errorf("NATURAL JOIN needs a catalog to find common fields")
+	}
+	leftFields := make(map[string]struct{})
+	for _, field := range mapper.sideFields(join.LeftExpr) {
+		leftFields[field] = struct{}{}
+	}
+	common := make([]string, 0)
+	seen := make(map[string]struct{})
+	for _, field := range mapper.sideFields(join.RightExpr) {
+		if _, ok := leftFields[field]; !ok {
+			continue
+		}
+		if _, ok := seen[field]; ok {
+			continue
+		}
+		seen[field] = struct{}{}
+		common = append(common, field)
+	}
+	return common, nil
+}
+
+func (mapper *SQLMapper) sideFields(side sqlparser.TableExpr) []string {
+	fields := make([]string, 0)
+	for _, q := range tableQualifiers(side) {
+		if ref, ok := mapper.scope.lookup(q); ok && !ref.derived {
+			fields = append(fields, mapper.Catalog.Fields(ref.topic)...)
+		}
+	}
+	return fields
+}
+
+// addsParent reports whether a FROM item is a topic.field array reference
+// that brings its own parent rows, and so maps to a join of its own.
+func (mapper *SQLMapper) addsParent(tableExpr sqlparser.TableExpr) bool {
+	qualifiers := tableQualifiers(tableExpr)
+	if _, ok := tableExpr.(*sqlparser.AliasedTableExpr); !ok || len(qualifiers) != 1 {
+		return false
+	}
+	ref, ok := mapper.scope.lookup(qualifiers[0])
+	return ok && ref.ownParent
+}
+
+// tableQualifiers returns the qualifiers of the tables in a FROM item, in
+// order.
+func tableQualifiers(tableExpr sqlparser.TableExpr) []string {
+	switch expr := tableExpr.(type) {
+	case *sqlparser.AliasedTableExpr:
+		if !expr.As.IsEmpty() {
+			return []string{expr.As.String()}
+		}
+		if table, ok := expr.Expr.(sqlparser.TableName); ok {
+			return []string{table.Name.String()}
+		}
+	case *sqlparser.JoinTableExpr:
+		return append(tableQualifiers(expr.LeftExpr), tableQualifiers(expr.RightExpr)...)
+	case *sqlparser.ParenTableExpr:
+		qualifiers := make([]string, 0)
+		for _, innerExpr := range expr.Exprs {
+			qualifiers = append(qualifiers, tableQualifiers(innerExpr)...)
+		}
+		return qualifiers
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+package converter
+
+import (
+	"testing"
+
+	"sqlalchemy/db"
+)
+
+var joinCatalog = db.StaticCatalog{
+	"pump_alarm":  {"code": db.FieldText, "pump_id": db.FieldInteger},
+	"maintenance": {"pump_id": db.FieldInteger, "done": db.FieldBoolean},
+	"devices":     {"id": db.FieldInteger, "name": db.FieldText},
+}
+
+// TestOuterJoinTopicConditions checks that the topic conditions of an outer
+// join's null-supplying side go into its ON clause and the others into WHERE.
+func TestOuterJoinTopicConditions(t *testing.T) {
+	tests := []struct {
+		name string
+		sql  string
+		want string
+	}{
+		{
+			name: "left join",
+			sql:  "SELECT a.code, m.done FROM pump_alarm a LEFT JOIN maintenance m ON m.pump_id = a.pump_id",
+			want: "SELECT (a.payload ->> 'code') AS code, (m.payload ->> 'done')::BOOLEAN AS done " +
+				"FROM tsdb_table AS a left join tsdb_table AS m ON (m.payload ->> 'pump_id')::BIGINT = (a.payload ->> 'pump_id')::BIGINT AND (m.payload ->> 'topic') = 'maintenance' " +
+				"WHERE (a.payload ->> 'topic') = 'pump_alarm'",
+		},
+		{
+			name: "right join",
+			sql:  "SELECT a.code, d.name FROM pump_alarm a RIGHT JOIN devices d ON d.id = a.pump_id",
+			want: "SELECT (a.payload ->> 'code') AS code, (d.payload ->> 'name') AS name " +
+				"FROM tsdb_table AS a right join tsdb_table AS d ON (d.payload ->> 'id')::BIGINT = (a.payload ->> 'pump_id')::BIGINT AND (a.payload ->> 'topic') = 'pump_alarm' " +
+				"WHERE (d.payload ->> 'topic') = 'devices'",
+		},
+		{
+			name: "left then inner then left",
+			sql: "SELECT a.code FROM pump_alarm a LEFT JOIN maintenance m ON m.pump_id = a.pump_id " +
+				"JOIN devices d ON d.id = a.pump_id LEFT JOIN maintenance m2 ON m2.pump_id = d.id",
+			want: "SELECT (a.payload ->> 'code') AS code " +
+				"FROM tsdb_table AS a left join tsdb_table AS m ON (m.payload ->> 'pump_id')::BIGINT = (a.payload ->> 'pump_id')::BIGINT AND (m.payload ->> 'topic') = 'maintenance' " +
+				"join tsdb_table AS d ON (d.payload ->> 'id')::BIGINT = (a.payload ->> 'pump_id')::BIGINT " +
+				"left join tsdb_table AS m2 ON (m2.payload ->> 'pump_id')::BIGINT = (d.payload ->> 'id')::BIGINT AND (m2.payload ->> 'topic') = 'maintenance' " +
+				"WHERE (a.payload ->> 'topic') = 'pump_alarm' AND (d.payload ->> 'topic') = 'devices'",
+		},
+		{
+			name: "left join using",
+			sql:  "SELECT a.code FROM pump_alarm a LEFT JOIN maintenance m USING (pump_id)",
+			want: "SELECT (a.payload ->> 'code') AS code " +
+				"FROM tsdb_table AS a left join tsdb_table AS m ON (a.payload ->> 'pump_id')::BIGINT = (m.payload ->> 'pump_id')::BIGINT AND (m.payload ->> 'topic') = 'maintenance' " +
+				"WHERE (a.payload ->> 'topic') = 'pump_alarm'",
+		},
+		{
+			name: "natural left join",
+			sql:  "SELECT a.code FROM pump_alarm a NATURAL LEFT JOIN maintenance m",
+			want: "SELECT (a.payload ->> 'code') AS code " +
+				"FROM tsdb_table AS a left join tsdb_table AS m ON (a.payload ->> 'pump_id')::BIGINT = (m.payload ->> 'pump_id')::BIGINT AND (m.payload ->> 'topic') = 'maintenance' " +
+				"WHERE (a.payload ->> 'topic') = 'pump_alarm'",
+		},
+		{
+			name: "right join using",
+			sql:  "SELECT m.done FROM pump_alarm a RIGHT JOIN maintenance m USING (pump_id)",
+			want: "SELECT (m.payload ->> 'done')::BOOLEAN AS done " +
+				"FROM tsdb_table AS a right join tsdb_table AS m ON (a.payload ->> 'pump_id')::BIGINT = (m.payload ->> 'pump_id')::BIGINT AND (a.payload ->> 'topic') = 'pump_alarm' " +
+				"WHERE (m.payload ->> 'topic') = 'maintenance'",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mapper, err := NewSQLMapper(tt.sql, joinCatalog, "tsdb_table", "payload", db.TopicKey{Name: "topic"}, nil)
+			if err != nil {
+				t.Fatalf("NewSQLMapper: %v", err)
+			}
+			if mapper.MappedSQL != tt.want {
+				t.Errorf("mapped SQL\n got: %s\nwant: %s", mapper.MappedSQL, tt.want)
+			}
+		})
+	}
+}
+
+// TestUsingAndNaturalJoins checks that USING and NATURAL joins become ON
+// conditions on the shared fields, that unqualified references to a shared
+// field read one side, and that a join without a condition is a cross join.
+func TestUsingAndNaturalJoins(t *testing.T) {
+	tests := []struct {
+		name string
+		sql  string
+		want string
+	}{
+		{
+			name: "using",
+			sql:  "SELECT pump_id, done FROM pump_alarm JOIN maintenance USING (pump_id)",
+			want: "SELECT (pump_alarm.payload ->> 'pump_id')::BIGINT AS pump_id, (maintenance.payload ->> 'done')::BOOLEAN AS done " +
+				"FROM tsdb_table AS pump_alarm join tsdb_table AS maintenance ON (pump_alarm.payload ->> 'pump_id')::BIGINT = (maintenance.payload ->> 'pump_id')::BIGINT " +
+				"WHERE (pump_alarm.payload ->> 'topic') = 'pump_alarm' AND (maintenance.payload ->> 'topic') = 'maintenance'",
+		},
+		{
+			name: "natural",
+			sql:  "SELECT pump_id FROM pump_alarm a NATURAL JOIN maintenance m",
+			want: "SELECT (a.payload ->> 'pump_id')::BIGINT AS pump_id " +
+				"FROM tsdb_table AS a join tsdb_table AS m ON (a.payload ->> 'pump_id')::BIGINT = (m.payload ->> 'pump_id')::BIGINT " +
+				"WHERE (a.payload ->> 'topic') = 'pump_alarm' AND (m.payload ->> 'topic') = 'maintenance'",
+		},
+		{
+			name: "using with a derived table",
+			sql:  "SELECT pump_id FROM (SELECT pump_id FROM maintenance) m JOIN pump_alarm a USING (pump_id)",
+			want: "SELECT m.pump_id AS pump_id " +
+				"FROM (SELECT (payload ->> 'pump_id')::BIGINT AS pump_id FROM tsdb_table AS maintenance WHERE (payload ->> 'topic') = 'maintenance') AS m " +
+				"join tsdb_table AS a ON m.pump_id = (a.payload ->> 'pump_id')::BIGINT " +
+				"WHERE (a.payload ->> 'topic') = 'pump_alarm'",
+		},
+		{
+			name: "cross join",
+			sql:  "SELECT a.code, d.name FROM pump_alarm a CROSS JOIN devices d",
+			want: "SELECT (a.payload ->> 'code') AS code, (d.payload ->> 'name') AS name " +
+				"FROM tsdb_table AS a cross join tsdb_table AS d " +
+				"WHERE (a.payload ->> 'topic') = 'pump_alarm' AND (d.payload ->> 'topic') = 'devices'",
+		},
+		{
+			name: "inner join without condition",
+			sql:  "SELECT a.code FROM pump_alarm a JOIN devices d",
+			want: "SELECT (a.payload ->> 'code') AS code " +
+				"FROM tsdb_table AS a cross join tsdb_table AS d " +
+				"WHERE (a.payload ->> 'topic') = 'pump_alarm' AND (d.payload ->> 'topic') = 'devices'",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mapper, err := NewSQLMapper(tt.sql, joinCatalog, "tsdb_table", "payload", db.TopicKey{Name: "topic"}, nil)
+			if err != nil {
+				t.Fatalf("NewSQLMapper: %v", err)
+			}
+			if mapper.MappedSQL != tt.want {
+				t.Errorf("mapped SQL\n got: %s\nwant: %s", mapper.MappedSQL, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package converter
+
+import (
+	"strings"
+
+	"github.com/xwb1989/sqlparser"
+)
+
+// Markers stand in for syntax that sqlparser tokenizes but cannot parse.
+// They are backquoted identifiers no real column or function is named.
+const (
+	// xorMarker: "a XOR b" is rewritten to "a OR `<xor>` OR b" and the OR
+	// chain is split back into XOR groups by mapOrExpr.
+	xorMarker = "<xor>"
+	// distinctOnMarker: "DISTINCT ON (a, b) x" is rewritten to the select
+	// expressions "`<distinct on>`(a, b), x" and restored by
+	// mapSelectStatement.
+	distinctOnMarker = "<distinct on>"
+)
+
+// normalizeSQL rewrites the syntax sqlparser rejects into a form it accepts:
+// XOR and PostgreSQL's DISTINCT ON become their markers, and IS [NOT] UNKNOWN
+// becomes IS [NOT] NULL, which means the same for boolean operands.
+func normalizeSQL(sql string) string {
+	var b strings.Builder
+	last := 0
+	prev, prevNot := 0, false
+	distinctStart := -1
+	// depth counts open parentheses inside DISTINCT ON; 0 when not in one.
+	depth := 0
+	tokenizer := sqlparser.NewStringTokenizer(sql)
+	for {
+		typ, val := tokenizer.Scan()
+		if typ == 0 || typ == sqlparser.LEX_ERROR {
+			break
+		}
+		end := tokenizer.Position - 1
+		start := end - len(val)
+		if len(val) == 0 {
+			start = end - 1
+		}
+		// Quoted tokens do not match their source text and are left alone.
+		if start >= last && (len(val) == 0 || strings.EqualFold(sql[start:end], string(val))) {
+			switch {
+			case typ == sqlparser.UNUSED && strings.EqualFold(string(val), "xor"):
+				b.WriteString(sql[last:start])
+				b.WriteString("OR `" + xorMarker + "` OR")
+				last = end
+			case typ == sqlparser.ID && strings.EqualFold(string(val), "unknown") && (prev == sqlparser.IS || prevNot):
+				b.WriteString(sql[last:start])
+				b.WriteString("NULL")
+				last = end
+			case typ == sqlparser.DISTINCT:
+				distinctStart = start
+			case typ == sqlparser.ON && prev == sqlparser.DISTINCT && distinctStart >= last:
+				b.WriteString(sql[last:distinctStart])
+				b.WriteString("`" + distinctOnMarker + "`")
+				last = end
+				depth = -1
+			case typ == '(' && depth != 0:
+				if depth < 0 {
+					depth = 0
+				}
+				depth++
+			case typ == ')' && depth > 0:
+				depth--
+				if depth == 0 {
+					b.WriteString(sql[last:end])
+					b.WriteString(",")
+					last = end
+				}
+			}
+		}
+		prevNot = typ == sqlparser.NOT && prev == sqlparser.IS
+		prev = typ
+	}
+	b.WriteString(sql[last:])
+	return b.String()
+}
@@ -0,0 +1,115 @@
+package converter
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/xwb1989/sqlparser"
+)
+
+// patternOperators translates MySQL pattern operators into PostgreSQL ones,
+// indexed by whether the match is case-insensitive.
+var patternOperators = map[string][2]string{
+	sqlparser.LikeStr:      {"LIKE", "ILIKE"},
+	sqlparser.NotLikeStr:   {"NOT LIKE", "NOT ILIKE"},
+	sqlparser.RegexpStr:    {"~", "~*"},
+	sqlparser.NotRegexpStr: {"!~", "!~*"},
+}
+
+func isPatternOperator(operator string) bool {
+	_, ok := patternOperators[operator]
+	return ok
+}
+
+// mapPatternExpr maps LIKE, NOT LIKE, REGEXP (RLIKE) and NOT REGEXP. A match
+// is case-insensitive when both sides are folded with the same LOWER/UPPER
+// (or the pattern literal is already folded), or when either side carries a
+// _ci collation. A BINARY operand, as in LIKE BINARY 'A%', asks for a
+// case-sensitive match, which is what PostgreSQL's LIKE and ~ already do, so
+// the keyword is dropped. ESCAPE clauses are kept, and columns are matched as
+// text without their catalog cast.
+func (mapper *SQLMapper) mapPatternExpr(e *sqlparser.ComparisonExpr) string {
+	left, leftBinary := unwrapBinary(e.Left)
+	right, rightBinary := unwrapBinary(e.Right)
+	insensitive := false
+	if !leftBinary && !rightBinary {
+		var leftCI, rightCI bool
+		left, right, insensitive = unwrapCaseFold(left, right)
+		left, leftCI = unwrapCollation(left)
+		right, rightCI = unwrapCollation(right)
+		insensitive = insensitive || leftCI || rightCI
+	}
+
+	operator := patternOperators[e.Operator][0]
+	if insensitive {
+		operator = patternOperators[e.Operator][1]
+	}
+
+	mapped := fmt.Sprintf("%s %s %s", mapper.mapPatternOperand(left), operator, mapper.mapPatternOperand(right))
+	if e.Escape != nil {
+		mapped += " ESCAPE " + mapper.mapExpr(e.Escape)
+	}
+	return mapped
+}
+
+func (mapper *SQLMapper) mapPatternOperand(expr sqlparser.Expr) string {
+	if col, ok := expr.(*sqlparser.ColName); ok {
+		return mapper.columnAccess(col)
+	}
+	return mapper.mapExpr(expr)
+}
+
+// unwrapCaseFold strips LOWER()/UPPER() from both sides of a match, or from
+// the subject when the pattern is a literal that is already folded that way.
+func unwrapCaseFold(left, right sqlparser.Expr) (sqlparser.Expr, sqlparser.Expr, bool) {
+	fold, inner, ok := caseFold(left)
+	if !ok {
+		return left, right, false
+	}
+	if rightFold, rightInner, ok := caseFold(right); ok && rightFold == fold {
+		return inner, rightInner, true
+	}
+	if val, ok := right.(*sqlparser.SQLVal); ok && val.Type == sqlparser.StrVal {
+		pattern := string(val.Val)
+		if (fold == "lower" && strings.ToLower(pattern) == pattern) || (fold == "upper" && strings.ToUpper(pattern) == pattern) {
+			return inner, right, true
+		}
+	}
+	return left, right, false
+}
+
+func caseFold(expr sqlparser.Expr) (string, sqlparser.Expr, bool) {
+	fn, ok := expr.(*sqlparser.FuncExpr)
+	if !ok || len(fn.Exprs) != 1 {
+		return "", nil, false
+	}
+	name := fn.Name.Lowered()
+	if name != "lower" && name != "upper" && name != "lcase" && name != "ucase" {
+		return "", nil, false
+	}
+	arg, ok := fn.Exprs[0].(*sqlparser.AliasedExpr)
+	if !ok {
+		return "", nil, false
+	}
+	if name == "lcase" {
+		name = "lower"
+	} else if name == "ucase" {
+		name = "upper"
+	}
+	return name, arg.Expr, true
+}
+
+func unwrapCollation(expr sqlparser.Expr) (sqlparser.Expr, bool) {
+	if c, ok := expr.(*sqlparser.CollateExpr); ok {
+		return c.Expr, strings.HasSuffix(strings.ToLower(c.Charset), "_ci")
+	}
+	return expr, false
+}
+
+// unwrapBinary strips a BINARY cast from a match operand.
+func unwrapBinary(expr sqlparser.Expr) (sqlparser.Expr, bool) {
+	if u, ok := expr.(*sqlparser.UnaryExpr); ok && u.Operator == sqlparser.BinaryStr {
+		return u.Expr, true
+	}
+	return expr, false
+}
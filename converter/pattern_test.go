@@ -0,0 +1,59 @@
+package converter
+
+import (
+	"testing"
+
+	"sqlalchemy/db"
+)
+
+var patternCatalog = db.StaticCatalog{
+	"pump_alarm": {"code": db.FieldText},
+}
+
+// TestPatternOperators checks which PostgreSQL operator a MySQL match maps
+// to and that BINARY only makes the match case-sensitive.
+func TestPatternOperators(t *testing.T) {
+	tests := []struct {
+		name string
+		sql  string
+		want string
+	}{
+		{
+			name: "like",
+			sql:  "SELECT code FROM pump_alarm WHERE code LIKE 'A%'",
+			want: "SELECT (payload ->> 'code') AS code FROM tsdb_table AS pump_alarm WHERE (payload ->> 'topic') = 'pump_alarm' AND (payload ->> 'code') LIKE 'A%'",
+		},
+		{
+			name: "case-insensitive collation",
+			sql:  "SELECT code FROM pump_alarm WHERE code LIKE 'a%' COLLATE utf8_general_ci",
+			want: "SELECT (payload ->> 'code') AS code FROM tsdb_table AS pump_alarm WHERE (payload ->> 'topic') = 'pump_alarm' AND (payload ->> 'code') ILIKE 'a%'",
+		},
+		{
+			name: "like binary",
+			sql:  "SELECT code FROM pump_alarm WHERE code LIKE BINARY 'A%'",
+			want: "SELECT (payload ->> 'code') AS code FROM tsdb_table AS pump_alarm WHERE (payload ->> 'topic') = 'pump_alarm' AND (payload ->> 'code') LIKE 'A%'",
+		},
+		{
+			name: "binary subject",
+			sql:  "SELECT code FROM pump_alarm WHERE BINARY LOWER(code) NOT LIKE 'a%'",
+			want: "SELECT (payload ->> 'code') AS code FROM tsdb_table AS pump_alarm WHERE (payload ->> 'topic') = 'pump_alarm' AND LOWER((payload ->> 'code')) NOT LIKE 'a%'",
+		},
+		{
+			name: "regexp binary",
+			sql:  "SELECT code FROM pump_alarm WHERE code REGEXP BINARY '^A'",
+			want: "SELECT (payload ->> 'code') AS code FROM tsdb_table AS pump_alarm WHERE (payload ->> 'topic') = 'pump_alarm' AND (payload ->> 'code') ~ '^A'",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mapper, err := NewSQLMapper(tt.sql, patternCatalog, "tsdb_table", "payload", db.TopicKey{Name: "topic"}, nil)
+			if err != nil {
+				t.Fatalf("NewSQLMapper: %v", err)
+			}
+			if mapper.MappedSQL != tt.want {
+				t.Errorf("mapped SQL\n got: %s\nwant: %s", mapper.MappedSQL, tt.want)
+			}
+		})
+	}
+}
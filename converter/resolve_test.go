@@ -0,0 +1,103 @@
+package converter
+
+import (
+	"testing"
+
+	"sqlalchemy/db"
+)
+
+var resolveCatalog = db.StaticCatalog{
+	"pump_alarm":      {"code": db.FieldText, "pump_id": db.FieldInteger, "value": db.FieldNumeric, "tags": db.FieldArray},
+	"pump_alarm.tags": {"value": db.FieldText},
+	"devices":         {"id": db.FieldInteger, "name": db.FieldText, "value": db.FieldText},
+}
+
+// TestUnqualifiedColumns checks that an unqualified column is read from the
+// one table of the query, or of an enclosing query, that has the field.
+func TestUnqualifiedColumns(t *testing.T) {
+	tests := []struct {
+		name string
+		sql  string
+		want string
+	}{
+		{
+			name: "join",
+			sql:  "SELECT code, name FROM pump_alarm a JOIN devices d ON id = pump_id",
+			want: "SELECT (a.payload ->> 'code') AS code, (d.payload ->> 'name') AS name " +
+				"FROM tsdb_table AS a join tsdb_table AS d ON (d.payload ->> 'id')::BIGINT = (a.payload ->> 'pump_id')::BIGINT " +
+				"WHERE (a.payload ->> 'topic') = 'pump_alarm' AND (d.payload ->> 'topic') = 'devices'",
+		},
+		{
+			name: "array element over implicit parent",
+			sql:  "SELECT value FROM pump_alarm.tags",
+			want: "SELECT tags.value AS value " +
+				"FROM tsdb_table AS pump_alarm CROSS JOIN LATERAL jsonb_array_elements_text(pump_alarm.payload -> 'tags') AS tags(value) " +
+				"WHERE (pump_alarm.payload ->> 'topic') = 'pump_alarm'",
+		},
+		{
+			name: "correlated",
+			sql:  "SELECT code FROM pump_alarm a WHERE EXISTS (SELECT 1 FROM devices d WHERE id = pump_id)",
+			want: "SELECT (payload ->> 'code') AS code FROM tsdb_table AS a WHERE (a.payload ->> 'topic') = 'pump_alarm' " +
+				"AND EXISTS (SELECT 1 FROM tsdb_table AS d WHERE (d.payload ->> 'topic') = 'devices' AND (payload ->> 'id')::BIGINT = (a.payload ->> 'pump_id')::BIGINT)",
+		},
+		{
+			name: "derived table and topic",
+			sql:  "SELECT total FROM (SELECT SUM(value) AS total FROM pump_alarm) s, devices d WHERE d.id = 1",
+			want: "SELECT s.total AS total " +
+				"FROM (SELECT SUM((payload ->> 'value')::NUMERIC) AS total FROM tsdb_table AS pump_alarm WHERE (payload ->> 'topic') = 'pump_alarm') AS s, tsdb_table AS d " +
+				"WHERE (d.payload ->> 'topic') = 'devices' AND (d.payload ->> 'id')::BIGINT = 1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mapper, err := NewSQLMapper(tt.sql, resolveCatalog, "tsdb_table", "payload", db.TopicKey{Name: "topic"}, nil)
+			if err != nil {
+				t.Fatalf("NewSQLMapper: %v", err)
+			}
+			if mapper.MappedSQL != tt.want {
+				t.Errorf("mapped SQL\n got: %s\nwant: %s", mapper.MappedSQL, tt.want)
+			}
+		})
+	}
+}
+
+// TestUnresolvableColumns checks the errors for unqualified columns that
+// several tables, or none, provide.
+func TestUnresolvableColumns(t *testing.T) {
+	tests := []struct {
+		name string
+		sql  string
+		want string
+	}{
+		{
+			name: "two topics",
+			sql:  "SELECT value FROM pump_alarm a JOIN devices d ON d.id = a.pump_id",
+			want: "column value is ambiguous: it is a column of a, d",
+		},
+		{
+			name: "array element and named parent",
+			sql:  "SELECT value FROM pump_alarm p, p.tags t",
+			want: "column value is ambiguous: it is a column of p, t",
+		},
+		{
+			name: "derived table and topic",
+			sql:  "SELECT name FROM (SELECT name FROM devices) s, devices d",
+			want: "column name is ambiguous: it is a column of s, d",
+		},
+		{
+			name: "no table",
+			sql:  "SELECT nope FROM pump_alarm a, devices d",
+			want: "column nope is not a field of any table in FROM",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewSQLMapper(tt.sql, resolveCatalog, "tsdb_table", "payload", db.TopicKey{Name: "topic"}, nil)
+			if err == nil || err.Error() != tt.want {
+				t.Errorf("error\n got: %v\nwant: %s", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,376 @@
+package converter
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/xwb1989/sqlparser"
+
+	"sqlalchemy/db"
+)
+
+// tableRef is one table visible to a SELECT.
+type tableRef struct {
+	topic string
+	// arrayOf is set for the elements of a JSON array field: it names the
+	// qualifier of the row that holds the array.
+	arrayOf string
+	// ownParent marks an array reference written as topic.field, whose
+	// parent row is added to the FROM clause by the mapper itself.
+	ownParent bool
+	// scalar marks an array of scalars: its only column is the element value.
+	scalar bool
+	// derived marks a subquery in FROM or a WITH query; it has real
+	// columns, not a topic.
+	derived bool
+	// columns lists a derived table's columns by lower-case name; nil when
+	// they are not known.
+	columns map[string]string
+}
+
+// scope records the tables visible to one SELECT, keyed by the qualifier
+// column references use to reach them (the alias, or the bare table name).
+type scope struct {
+	parent *scope
+	tables map[string]*tableRef
+	order  []string
+	// aliases maps the select-list aliases GROUP BY, HAVING and ORDER BY
+	// may use to the expressions they name.
+	aliases map[string]string
+	// grouped holds the bare column names of GROUP BY, and having is set
+	// while HAVING is mapped, where an alias wins over any other column.
+	grouped map[string]struct{}
+	having  bool
+	// outputs holds the lower-case output column names of the select list,
+	// so that expanded stars give each field a name of its own.
+	outputs map[string]struct{}
+	// joined maps each USING or NATURAL join field to the qualifiers of the
+	// tables that share it, each pointing at the one unqualified references
+	// read.
+	joined map[string]map[string]string
+}
+
+func (s *scope) hasDerived() bool {
+	for _, ref := range s.tables {
+		if ref.derived {
+			return true
+		}
+	}
+	return false
+}
+
+func newScope(parent *scope) *scope {
+	return &scope{
+		parent: parent,
+		tables: make(map[string]*tableRef),
+	}
+}
+
+func (s *scope) add(qualifier string, ref *tableRef) {
+	if _, ok := s.tables[qualifier]; !ok {
+		s.order = append(s.order, qualifier)
+	}
+	s.tables[qualifier] = ref
+}
+
+// lookup resolves a qualifier against this scope and then its parents,
+// so correlated references reach the outer query's tables.
+func (s *scope) lookup(qualifier string) (*tableRef, bool) {
+	for cur := s; cur != nil; cur = cur.parent {
+		if ref, ok := cur.tables[qualifier]; ok {
+			return ref, true
+		}
+	}
+	return nil, false
+}
+
+func (mapper *SQLMapper) pushScope(from sqlparser.TableExprs) {
+	mapper.scope = newScope(mapper.scope)
+	for _, tableExpr := range from {
+		mapper.collectTopics(tableExpr)
+	}
+}
+
+func (mapper *SQLMapper) popScope() {
+	mapper.scope = mapper.scope.parent
+}
+
+func (mapper *SQLMapper) collectTopics(tableExpr sqlparser.TableExpr) {
+	switch expr := tableExpr.(type) {
+	case *sqlparser.AliasedTableExpr:
+		table, ok := expr.Expr.(sqlparser.TableName)
+		if !ok {
+			if subquery, ok := expr.Expr.(*sqlparser.Subquery); ok && !expr.As.IsEmpty() {
+				mapper.scope.add(expr.As.String(), &tableRef{derived: true, columns: derivedColumns(subquery.Select)})
+			}
+			return
+		}
+		tableName := table.Name.String()
+		if tableName == "" || tableName == mapper.TableName {
+			return
+		}
+		qualifier := tableName
+		if !expr.As.IsEmpty() {
+			qualifier = expr.As.String()
+		}
+		if _, ok := mapper.scope.tables[qualifier]; ok {
+			// Both references would be emitted under the same alias.
+			mapper.fail(fmt.Errorf("table %s is used more than once in FROM; give each reference its own alias", qualifier))
+			return
+		}
+		if ref, ok := mapper.commonTables[tableName]; ok && table.Qualifier.IsEmpty() {
+			mapper.scope.add(qualifier, ref)
+			return
+		}
+		if table.Qualifier.IsEmpty() {
+			mapper.scope.add(qualifier, &tableRef{topic: tableName})
+			return
+		}
+		ref := mapper.arrayRef(table)
+		if ref == nil && mapper.isTopic(tableName) {
+			// schema.topic: every topic lives in the payload table, so
+			// the schema adds nothing.
+			ref = &tableRef{topic: tableName}
+		}
+		if ref == nil {
+			mapper.fail(fmt.Errorf("table %s is neither a topic nor an array field of one", sqlparser.String(table)))
+			return
+		}
+		mapper.scope.add(qualifier, ref)
+	case *sqlparser.JoinTableExpr:
+		mapper.collectTopics(expr.LeftExpr)
+		mapper.collectTopics(expr.RightExpr)
+		mapper.addJoinedFields(expr)
+	case *sqlparser.ParenTableExpr:
+		for _, innerExpr := range expr.Exprs {
+			mapper.collectTopics(innerExpr)
+		}
+	}
+}
+
+// arrayRef resolves a parent.field table reference to the elements of a JSON
+// array. The parent is an earlier table of the same FROM clause or of an
+// enclosing query, or else a topic name, in which case the mapper adds that
+// topic's rows under the topic's own name. It returns nil when the catalog
+// has no such array.
+func (mapper *SQLMapper) arrayRef(table sqlparser.TableName) *tableRef {
+	parent := table.Qualifier.String()
+	field := table.Name.String()
+	ref := &tableRef{arrayOf: parent}
+
+	parentRef, ok := mapper.scope.lookup(parent)
+	if !ok {
+		parentRef = &tableRef{topic: parent}
+		ref.ownParent = true
+	}
+	if mapper.Catalog != nil && !parentRef.derived {
+		// With a catalog, only array fields of a known topic qualify;
+		// anything else, such as schema.table, is not an array table.
+		fieldType, _ := mapper.Catalog.FieldType(parentRef.topic, field)
+		if fieldType != db.FieldArray && fieldType != db.FieldObjectArray {
+			return nil
+		}
+		ref.scalar = fieldType == db.FieldArray
+	}
+	if ref.ownParent {
+		mapper.scope.add(parent, parentRef)
+	}
+	ref.topic = parentRef.topic + "." + field
+	return ref
+}
+
+// isTopic reports whether the catalog lists name as a topic.
+func (mapper *SQLMapper) isTopic(name string) bool {
+	return mapper.Catalog != nil && slices.Contains(mapper.Catalog.Topics(), name)
+}
+
+// columnTopics returns the topics a column reference may belong to: the
+// topic behind its (possibly resolved) qualifier, or every topic of the
+// innermost scope when the reference stays unqualified.
+func (mapper *SQLMapper) columnTopics(col *sqlparser.ColName) []string {
+	if mapper.scope == nil {
+		return nil
+	}
+	qualifier := col.Qualifier.Name.String()
+	if qualifier == "" {
+		qualifier = mapper.resolveQualifier(col)
+	}
+	if qualifier != "" {
+		if ref, ok := mapper.scope.lookup(qualifier); ok {
+			return []string{ref.topic}
+		}
+		return nil
+	}
+	topics := make([]string, 0, len(mapper.scope.order))
+	for _, q := range mapper.scope.order {
+		topics = append(topics, mapper.scope.tables[q].topic)
+	}
+	return topics
+}
+
+// resolveQualifier picks the table an unqualified column reads from where
+// the bare payload column would be ambiguous or bind to the wrong relation:
+//   - in a FROM clause with several tables, the column goes to the table
+//     whose topic has the field (see columnOwners), and is ambiguous when
+//     several have it;
+//   - a field none of the query's own tables has is a correlated reference,
+//     and goes to the nearest enclosing query's table that has it.
+//
+// A field no table has in a FROM clause with several payload columns is an
+// error.
+func (mapper *SQLMapper) resolveQualifier(col *sqlparser.ColName) string {
+	if mapper.scope == nil || mapper.Catalog == nil {
+		return ""
+	}
+	if owners := mapper.columnOwners(mapper.scope, col); len(owners) > 0 {
+		if len(mapper.scope.order) == 1 {
+			return ""
+		}
+		return mapper.ownerIn(mapper.scope, col, owners)
+	}
+	if mapper.scope.hasDerived() {
+		// The column may be one of the derived table's own columns.
+		return ""
+	}
+	for s := mapper.scope.parent; s != nil; s = s.parent {
+		if owners := mapper.columnOwners(s, col); len(owners) > 0 {
+			return mapper.ownerIn(s, col, owners)
+		}
+	}
+	if mapper.scope.payloadTables() > 1 {
+		mapper.fail(fmt.Errorf("column %s is not a field of any table in FROM", sqlparser.String(col)))
+	}
+	return ""
+}
+
+// ownerIn returns the one table of s that provides a column, or fails when
+// several do.
+func (mapper *SQLMapper) ownerIn(s *scope, col *sqlparser.ColName, owners []string) string {
+	if len(owners) > 1 {
+		return mapper.fail(fmt.Errorf("column %s is ambiguous: it is a column of %s", sqlparser.String(col), strings.Join(owners, ", ")))
+	}
+	return owners[0]
+}
+
+// columnOwners returns the tables of s that provide an unqualified column:
+// derived tables listing it and topics or array elements with the field.
+// Tables matched on the field by a USING or NATURAL join count once, as the
+// table unqualified references read, and a topic the mapper added only as
+// the parent of an array table gives way to the array's elements.
+func (mapper *SQLMapper) columnOwners(s *scope, col *sqlparser.ColName) []string {
+	name := strings.ToLower(strings.ReplaceAll(col.Name.String(), ".", nestedSeparator))
+	found := make([]string, 0)
+	for _, q := range s.order {
+		if _, ok := s.tables[q].columns[name]; ok {
+			found = append(found, q)
+		}
+	}
+	if mapper.Catalog != nil {
+		found = append(found, mapper.fieldOwners(s, fieldNames(col))...)
+	}
+
+	owners := make([]string, 0, len(found))
+	for _, q := range found {
+		if len(found) > 1 && s.addedParent(q) {
+			continue
+		}
+		for _, field := range fieldNames(col) {
+			if target, ok := s.joined[field][q]; ok {
+				q = target
+				break
+			}
+		}
+		if !slices.Contains(owners, q) {
+			owners = append(owners, q)
+		}
+	}
+	return owners
+}
+
+// addedParent reports whether q is a topic the mapper added to s as the
+// parent of a topic.field array table.
+func (s *scope) addedParent(q string) bool {
+	for _, ref := range s.tables {
+		if ref.ownParent && ref.arrayOf == q {
+			return true
+		}
+	}
+	return false
+}
+
+// fieldOwners returns the qualifiers of the tables in s whose topic has one
+// of the field names, in FROM order.
+func (mapper *SQLMapper) fieldOwners(s *scope, names []string) []string {
+	owners := make([]string, 0)
+	for _, q := range s.order {
+		ref := s.tables[q]
+		for _, name := range names {
+			_, ok := mapper.Catalog.FieldType(ref.topic, name)
+			if ok || (ref.scalar && name == scalarElementCol) {
+				owners = append(owners, q)
+				break
+			}
+		}
+	}
+	return owners
+}
+
+// payloadTables counts the tables of s that have a payload column.
+func (s *scope) payloadTables() int {
+	n := 0
+	for _, ref := range s.tables {
+		if !ref.derived && !ref.scalar {
+			n++
+		}
+	}
+	return n
+}
+
+// fieldCast returns the PostgreSQL cast for the column in the topic it
+// belongs to. Without a catalog the flat NumericFields set is used; real
+// columns are not cast.
+func (mapper *SQLMapper) fieldCast(col *sqlparser.ColName) string {
+	if _, ok := mapper.boundColumn(col); ok {
+		return ""
+	}
+	return mapper.topicFieldCast(mapper.columnTopics(col), strings.Join(mapper.fieldPath(col), "."))
+}
+
+// topicFieldCast returns the cast for a field of the first of the topics
+// that has it.
+func (mapper *SQLMapper) topicFieldCast(topics []string, field string) string {
+	if mapper.Catalog == nil {
+		if _, ok := mapper.NumericFields[field]; ok {
+			return "::FLOAT"
+		}
+		return ""
+	}
+	for _, topic := range topics {
+		if fieldType, ok := mapper.Catalog.FieldType(topic, field); ok {
+			return castFor(fieldType)
+		}
+	}
+	return ""
+}
+
+func castFor(fieldType db.FieldType) string {
+	switch fieldType {
+	case db.FieldInteger:
+		return "::BIGINT"
+	case db.FieldNumeric:
+		return "::NUMERIC"
+	case db.FieldBoolean:
+		return "::BOOLEAN"
+	case db.FieldTimestamp:
+		return "::TIMESTAMPTZ"
+	case db.FieldDate:
+		return "::DATE"
+	case db.FieldUUID:
+		return "::UUID"
+	case db.FieldArray, db.FieldObjectArray:
+		return "::JSONB"
+	default:
+		return ""
+	}
+}
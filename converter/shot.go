@@ -2,8 +2,6 @@ package converter
 
 import (
 	"fmt"
-	"regexp"
-	"strings"
 
 	"sqlalchemy/db"
 )
@@ -17,6 +15,8 @@ func MapSQLShot(
 	table string,
 	payloadCol string,
 	topicField string,
+	topicIsColumn bool,
+	functions map[string]string,
 	originalSQL string,
 ) (string, error) {
 
@@ -27,43 +27,36 @@ func MapSQLShot(
 		User:     user,
 		Password: password,
 	}
+	topicKey := db.TopicKey{Name: topicField, IsColumn: topicIsColumn}
 
-	topicFields, err := db.LoadNumericFields(cfg, table, payloadCol)
+	service, err := db.SharedSchemaService(cfg, table, payloadCol, topicKey)
 	if err != nil {
-		return "", fmt.Errorf("load numeric fields failed: %w", err)
+		return "", fmt.Errorf("open schema service failed: %w", err)
 	}
-
-	numericFields := make(map[string]struct{})
-	for _, fields := range topicFields {
-		for f := range fields {
-			numericFields[f] = struct{}{}
-		}
+	catalog, err := service.Catalog()
+	if err != nil {
+		return "", fmt.Errorf("load catalog failed: %w", err)
 	}
 
-	// 处理 SELECT * 的情况
-	allFields, err := db.LoadAllFields(cfg, table, payloadCol)
+	funcs, err := TemplateFuncs(functions)
 	if err != nil {
-		return "", fmt.Errorf("load all fields failed: %w", err)
+		return "", fmt.Errorf("load functions failed: %w", err)
 	}
-	re := regexp.MustCompile(`(?i)SELECT\s+\*\s+FROM\s+(\S+)`)
-	matches := re.FindStringSubmatch(originalSQL)
-	if len(matches) == 2 {
-		fromTable := matches[1]
 
-		fields, ok := allFields[fromTable]
-		if ok && len(fields) > 0 {
-			fieldExprs := make([]string, 0, len(fields))
-			for _, f := range fields {
-				expr := fmt.Sprintf("(%s ->> '%s') AS %s", payloadCol, f, f)
-				fieldExprs = append(fieldExprs, expr)
-			}
-			fieldsStr := strings.Join(fieldExprs, ", ")
-			// 替换 * 为具体字段
-			originalSQL = re.ReplaceAllString(originalSQL, fmt.Sprintf("SELECT %s FROM %s", fieldsStr, fromTable))
-		}
-	}
+	return MapSQL(catalog, table, payloadCol, topicKey, funcs, originalSQL)
+}
+
+// MapSQL maps originalSQL against any catalog, without a database connection.
+func MapSQL(
+	catalog db.Catalog,
+	table string,
+	payloadCol string,
+	topicKey db.TopicKey,
+	funcs map[string]FuncRewrite,
+	originalSQL string,
+) (string, error) {
 
-	mapper, err := NewSQLMapper(originalSQL, numericFields, table, payloadCol, topicField)
+	mapper, err := NewSQLMapper(originalSQL, catalog, table, payloadCol, topicKey, funcs)
 	if err != nil {
 		return "", fmt.Errorf("SQL parse/map failed: %w", err)
 	}
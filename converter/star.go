@@ -0,0 +1,132 @@
+package converter
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/xwb1989/sqlparser"
+)
+
+// expandStar replaces * and alias.* with the catalog fields of the topics in
+// scope, each cast to its catalog type. An unqualified * covers the tables
+// the query names, not a parent topic the mapper added for an array, and
+// over several tables prefixes a field's alias with its table qualifier when
+// more than one table has that field. An alias the select list already uses
+// is prefixed in the same way, and numbered if still taken. Tables the
+// catalog cannot describe, such as derived tables, keep their star.
+func (mapper *SQLMapper) expandStar(star *sqlparser.StarExpr) string {
+	qualifiers := make([]string, 0, len(mapper.scope.order))
+	if !star.TableName.IsEmpty() {
+		qualifiers = append(qualifiers, star.TableName.Name.String())
+	} else {
+		for _, q := range mapper.scope.order {
+			if !mapper.scope.addedParent(q) {
+				qualifiers = append(qualifiers, q)
+			}
+		}
+	}
+	qualify := len(mapper.scope.order) > 1 || !star.TableName.IsEmpty()
+
+	seen := make(map[string]int)
+	fields := make([][]string, len(qualifiers))
+	for i, q := range qualifiers {
+		fields[i] = mapper.starFields(q)
+		for _, f := range fields[i] {
+			seen[f]++
+		}
+	}
+
+	exprs := make([]string, 0)
+	for i, q := range qualifiers {
+		ref, ok := mapper.scope.lookup(q)
+		if !ok || fields[i] == nil {
+			if !qualify {
+				return "*"
+			}
+			exprs = append(exprs, quoteIdent(q)+".*")
+			continue
+		}
+		qualifier := ""
+		if qualify {
+			qualifier = q
+		}
+		for _, f := range fields[i] {
+			path := strings.Split(f, ".")
+			alias := fieldAlias(path)
+			if seen[f] > 1 || mapper.outputTaken(alias) {
+				alias = q + "_" + alias
+			}
+			alias = mapper.claimOutput(alias)
+			access := mapper.fieldAccess(qualifier, path)
+			if ref.scalar {
+				access = quoteIdent(q) + "." + scalarElementCol
+			}
+			cast := mapper.topicFieldCast([]string{ref.topic}, f)
+			exprs = append(exprs, fmt.Sprintf("%s%s AS %s", access, cast, quoteIdent(alias)))
+		}
+	}
+	if len(exprs) == 0 {
+		return "*"
+	}
+	return strings.Join(exprs, ", ")
+}
+
+// outputNames collects the output column names of the select list's items
+// other than stars, which expanded stars must not reuse.
+func (mapper *SQLMapper) outputNames(exprs sqlparser.SelectExprs) map[string]struct{} {
+	names := make(map[string]struct{})
+	for _, expr := range exprs {
+		ae, ok := expr.(*sqlparser.AliasedExpr)
+		if !ok {
+			continue
+		}
+		name := ae.As.String()
+		if name == "" {
+			switch e := ae.Expr.(type) {
+			case *sqlparser.ColName:
+				name = fieldAlias(mapper.fieldPath(e))
+			case *sqlparser.FuncExpr:
+				name = e.Name.String()
+			}
+		}
+		if name != "" {
+			names[strings.ToLower(name)] = struct{}{}
+		}
+	}
+	return names
+}
+
+// outputTaken reports whether the select list already has an output column
+// of that name.
+func (mapper *SQLMapper) outputTaken(name string) bool {
+	_, ok := mapper.scope.outputs[strings.ToLower(name)]
+	return ok
+}
+
+// claimOutput reserves an output column name for an expanded field, adding
+// a number when the name is taken.
+func (mapper *SQLMapper) claimOutput(name string) string {
+	if mapper.scope.outputs == nil {
+		mapper.scope.outputs = make(map[string]struct{})
+	}
+	claimed := name
+	for n := 2; mapper.outputTaken(claimed); n++ {
+		claimed = fmt.Sprintf("%s_%d", name, n)
+	}
+	mapper.scope.outputs[strings.ToLower(claimed)] = struct{}{}
+	return claimed
+}
+
+// starFields lists the catalog fields behind a qualifier, or nil when the
+// catalog does not describe it.
+func (mapper *SQLMapper) starFields(qualifier string) []string {
+	ref, ok := mapper.scope.lookup(qualifier)
+	if !ok || ref.derived || mapper.Catalog == nil {
+		return nil
+	}
+	fields := mapper.Catalog.Fields(ref.topic)
+	if len(fields) == 0 {
+		return nil
+	}
+	return fields
+}
@@ -0,0 +1,72 @@
+package converter
+
+import (
+	"testing"
+
+	"sqlalchemy/db"
+)
+
+var starCatalog = db.StaticCatalog{
+	"pump_alarm":          {"code": db.FieldText, "pump_id": db.FieldInteger, "readings": db.FieldObjectArray},
+	"pump_alarm.readings": {"value": db.FieldNumeric},
+	"maintenance":         {"pump_id": db.FieldInteger, "m_pump_id": db.FieldInteger},
+	"devices":             {"id": db.FieldInteger, "name": db.FieldText},
+}
+
+// TestExpandStar checks the fields * and alias.* expand to and the output
+// names they get.
+func TestExpandStar(t *testing.T) {
+	tests := []struct {
+		name string
+		sql  string
+		want string
+	}{
+		{
+			name: "single topic",
+			sql:  "SELECT * FROM devices",
+			want: "SELECT (payload ->> 'id')::BIGINT AS id, (payload ->> 'name') AS name " +
+				"FROM tsdb_table AS devices WHERE (payload ->> 'topic') = 'devices'",
+		},
+		{
+			name: "implicit parent",
+			sql:  "SELECT * FROM pump_alarm.readings r",
+			want: "SELECT (r.payload ->> 'value')::NUMERIC AS value " +
+				"FROM tsdb_table AS pump_alarm CROSS JOIN LATERAL jsonb_array_elements(pump_alarm.payload -> 'readings') AS r(payload) " +
+				"WHERE (pump_alarm.payload ->> 'topic') = 'pump_alarm'",
+		},
+		{
+			name: "several topics",
+			sql:  "SELECT * FROM pump_alarm a JOIN devices d ON d.id = a.pump_id",
+			want: "SELECT (a.payload ->> 'code') AS code, (a.payload ->> 'pump_id')::BIGINT AS pump_id, (a.payload ->> 'readings')::JSONB AS readings, " +
+				"(d.payload ->> 'id')::BIGINT AS id, (d.payload ->> 'name') AS name " +
+				"FROM tsdb_table AS a join tsdb_table AS d ON (d.payload ->> 'id')::BIGINT = (a.payload ->> 'pump_id')::BIGINT " +
+				"WHERE (a.payload ->> 'topic') = 'pump_alarm' AND (d.payload ->> 'topic') = 'devices'",
+		},
+		{
+			name: "qualified stars",
+			sql:  "SELECT a.*, m.* FROM pump_alarm a JOIN maintenance m ON m.pump_id = a.pump_id",
+			want: "SELECT (a.payload ->> 'code') AS code, (a.payload ->> 'pump_id')::BIGINT AS pump_id, (a.payload ->> 'readings')::JSONB AS readings, " +
+				"(m.payload ->> 'm_pump_id')::BIGINT AS m_pump_id, (m.payload ->> 'pump_id')::BIGINT AS m_pump_id_2 " +
+				"FROM tsdb_table AS a join tsdb_table AS m ON (m.payload ->> 'pump_id')::BIGINT = (a.payload ->> 'pump_id')::BIGINT " +
+				"WHERE (a.payload ->> 'topic') = 'pump_alarm' AND (m.payload ->> 'topic') = 'maintenance'",
+		},
+		{
+			name: "named column",
+			sql:  "SELECT name, * FROM devices",
+			want: "SELECT (payload ->> 'name') AS name, (payload ->> 'id')::BIGINT AS id, (payload ->> 'name') AS devices_name " +
+				"FROM tsdb_table AS devices WHERE (payload ->> 'topic') = 'devices'",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mapper, err := NewSQLMapper(tt.sql, starCatalog, "tsdb_table", "payload", db.TopicKey{Name: "topic"}, nil)
+			if err != nil {
+				t.Fatalf("NewSQLMapper: %v", err)
+			}
+			if mapper.MappedSQL != tt.want {
+				t.Errorf("mapped SQL\n got: %s\nwant: %s", mapper.MappedSQL, tt.want)
+			}
+		})
+	}
+}
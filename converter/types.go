@@ -1,11 +1,25 @@
 package converter
 
+import "sqlalchemy/db"
+
 type SQLMapper struct {
 	OriginalSQL   string
 	MappedSQL     string
 	NumericFields map[string]struct{}
+	Catalog       db.Catalog
 	AllFields     map[string][]string
 	TableName     string
 	PayloadCol    string
-	Topic         string
+	Topic         db.TopicKey
+	Funcs         map[string]FuncRewrite
+
+	scope *scope
+	// aggregates counts the aggregate calls whose arguments are being
+	// mapped; select aliases are not substituted inside them.
+	aggregates int
+	// commonTables holds the queries of the statement's WITH clause by name.
+	commonTables map[string]*tableRef
+	// err is the first error met while mapping; the map functions return
+	// strings, so it is reported once the whole statement has been walked.
+	err error
 }
@@ -0,0 +1,83 @@
+package converter
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// FuncRewrite expands a user-defined function call. It receives the call's
+// arguments already mapped to PostgreSQL and returns the replacement SQL.
+type FuncRewrite func(args []string) (string, error)
+
+var (
+	userFuncsMu sync.RWMutex
+	userFuncs   = make(map[string]FuncRewrite)
+)
+
+// RegisterFunc makes a user-defined function available to every mapper.
+// Names are case-insensitive; a registration replaces an earlier one of the
+// same name and takes precedence over the built-in MySQL functions.
+func RegisterFunc(name string, rewrite FuncRewrite) {
+	userFuncsMu.Lock()
+	defer userFuncsMu.Unlock()
+	userFuncs[strings.ToLower(name)] = rewrite
+}
+
+// lookupFunc returns the user-defined function of the given lower-case name,
+// preferring the mapper's own functions over registered ones.
+func (mapper *SQLMapper) lookupFunc(name string) (FuncRewrite, bool) {
+	if rewrite, ok := mapper.Funcs[name]; ok {
+		return rewrite, true
+	}
+	userFuncsMu.RLock()
+	defer userFuncsMu.RUnlock()
+	rewrite, ok := userFuncs[name]
+	return rewrite, ok
+}
+
+var placeholder = regexp.MustCompile(`\$(\d+)`)
+
+// TemplateFunc builds a FuncRewrite from an SQL template in which $1, $2, ...
+// stand for the mapped arguments, e.g. "($1 * $2) / NULLIF($3, 0)". Each
+// argument is substituted in parentheses so that operators in it cannot
+// bind to the template's. A call must pass exactly as many arguments as the
+// highest placeholder.
+func TemplateFunc(template string) (FuncRewrite, error) {
+	arity := 0
+	for _, m := range placeholder.FindAllStringSubmatch(template, -1) {
+		n, _ := strconv.Atoi(m[1])
+		if n == 0 {
+			return nil, fmt.Errorf("invalid placeholder $0 in template: %s", template)
+		}
+		if n > arity {
+			arity = n
+		}
+	}
+
+	return func(args []string) (string, error) {
+		if len(args) != arity {
+			return "", fmt.Errorf("template takes %d arguments, got %d", arity, len(args))
+		}
+		return placeholder.ReplaceAllStringFunc(template, func(p string) string {
+			n, _ := strconv.Atoi(p[1:])
+			return "(" + args[n-1] + ")"
+		}), nil
+	}, nil
+}
+
+// TemplateFuncs builds user-defined functions from name to template pairs,
+// the form they take in service configuration.
+func TemplateFuncs(templates map[string]string) (map[string]FuncRewrite, error) {
+	funcs := make(map[string]FuncRewrite, len(templates))
+	for name, template := range templates {
+		rewrite, err := TemplateFunc(template)
+		if err != nil {
+			return nil, fmt.Errorf("function %s: %v", name, err)
+		}
+		funcs[strings.ToLower(name)] = rewrite
+	}
+	return funcs, nil
+}
@@ -0,0 +1,50 @@
+package converter
+
+import (
+	"testing"
+
+	"sqlalchemy/db"
+)
+
+var udfCatalog = db.StaticCatalog{
+	"pump_alarm": {"value": db.FieldNumeric, "threshold": db.FieldNumeric},
+}
+
+// TestTemplateFunc checks that template arguments are substituted as units.
+func TestTemplateFunc(t *testing.T) {
+	funcs, err := TemplateFuncs(map[string]string{"pump_efficiency": "$1 * $2 / NULLIF($3, 0)"})
+	if err != nil {
+		t.Fatalf("TemplateFuncs: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		sql  string
+		want string
+	}{
+		{
+			name: "column arguments",
+			sql:  "SELECT pump_efficiency(value, threshold, 2) AS eff FROM pump_alarm",
+			want: "SELECT ((payload ->> 'value')::NUMERIC) * ((payload ->> 'threshold')::NUMERIC) / NULLIF((2), 0) AS eff " +
+				"FROM tsdb_table AS pump_alarm WHERE (payload ->> 'topic') = 'pump_alarm'",
+		},
+		{
+			name: "compound argument",
+			sql:  "SELECT pump_efficiency(value + 1, threshold, 2) AS eff FROM pump_alarm",
+			want: "SELECT ((payload ->> 'value')::NUMERIC + 1) * ((payload ->> 'threshold')::NUMERIC) / NULLIF((2), 0) AS eff " +
+				"FROM tsdb_table AS pump_alarm WHERE (payload ->> 'topic') = 'pump_alarm'",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mapper, err := NewSQLMapper(tt.sql, udfCatalog, "tsdb_table", "payload", db.TopicKey{Name: "topic"}, funcs)
+			if err != nil {
+				t.Fatalf("NewSQLMapper: %v", err)
+			}
+			if mapper.MappedSQL != tt.want {
+				t.Errorf("mapped SQL\n got: %s\nwant: %s", mapper.MappedSQL, tt.want)
+			}
+		})
+	}
+}
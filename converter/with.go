@@ -0,0 +1,123 @@
+package converter
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/xwb1989/sqlparser"
+)
+
+// commonTable is one query of a WITH clause.
+type commonTable struct {
+	name    string
+	columns []string
+	sql     string
+}
+
+// splitWith separates a leading WITH clause, which sqlparser cannot parse,
+// from the statement it prefixes. It returns the WITH queries in order and
+// the text of the statement.
+func splitWith(sql string) ([]commonTable, string, error) {
+	tokenizer := sqlparser.NewStringTokenizer(sql)
+	scan := func() (int, string, int, int) {
+		typ, val := tokenizer.Scan()
+		end := tokenizer.Position - 1
+		start := end - len(val)
+		if len(val) == 0 {
+			start = end - 1
+		}
+		return typ, string(val), start, end
+	}
+
+	if typ, _, _, _ := scan(); typ != sqlparser.WITH {
+		return nil, sql, nil
+	}
+	tables := make([]commonTable, 0)
+	for {
+		typ, val, _, _ := scan()
+		if typ == sqlparser.ID && strings.EqualFold(val, "recursive") {
+			return nil, "", fmt.Errorf("WITH RECURSIVE not supported")
+		}
+		if typ != sqlparser.ID {
+			return nil, "", fmt.Errorf("WITH: query name expected near '%s'", val)
+		}
+		table := commonTable{name: val}
+
+		typ, val, start, end := scan()
+		if typ == '(' {
+			for {
+				typ, val, _, _ = scan()
+				if typ != sqlparser.ID {
+					return nil, "", fmt.Errorf("WITH %s: column name expected near '%s'", table.name, val)
+				}
+				table.columns = append(table.columns, val)
+				if typ, _, _, _ = scan(); typ != ',' {
+					break
+				}
+			}
+			if typ != ')' {
+				return nil, "", fmt.Errorf("WITH %s: ')' expected after column names", table.name)
+			}
+			typ, val, start, end = scan()
+		}
+		if typ != sqlparser.AS {
+			return nil, "", fmt.Errorf("WITH %s: AS expected near '%s'", table.name, val)
+		}
+		if typ, val, _, end = scan(); typ != '(' {
+			return nil, "", fmt.Errorf("WITH %s: '(' expected near '%s'", table.name, val)
+		}
+
+		bodyStart := end
+		for depth := 1; depth > 0; {
+			typ, _, start, _ = scan()
+			switch typ {
+			case 0, sqlparser.LEX_ERROR:
+				return nil, "", fmt.Errorf("WITH %s: unbalanced parentheses", table.name)
+			case '(':
+				depth++
+			case ')':
+				depth--
+			}
+		}
+		table.sql = sql[bodyStart:start]
+		tables = append(tables, table)
+
+		if typ, _, start, _ = scan(); typ != ',' {
+			if typ == 0 {
+				return nil, "", fmt.Errorf("WITH: statement expected after the WITH queries")
+			}
+			return tables, sql[start:], nil
+		}
+	}
+}
+
+// mapWith maps the queries of a WITH clause in order, each seeing the ones
+// before it, and makes them visible to the statement as derived tables.
+func (mapper *SQLMapper) mapWith(tables []commonTable) (string, error) {
+	mapper.commonTables = make(map[string]*tableRef)
+	parts := make([]string, 0, len(tables))
+	for _, table := range tables {
+		stmt, err := sqlparser.Parse(table.sql)
+		if err != nil {
+			return "", fmt.Errorf("WITH %s: %v", table.name, err)
+		}
+		selectStmt, ok := stmt.(sqlparser.SelectStatement)
+		if !ok {
+			return "", fmt.Errorf("WITH %s: SQL Type not supported: %T", table.name, stmt)
+		}
+
+		name := quoteIdent(table.name)
+		ref := &tableRef{derived: true, columns: derivedColumns(selectStmt)}
+		if len(table.columns) > 0 {
+			ref.columns = columnSet(table.columns)
+			quoted := make([]string, 0, len(table.columns))
+			for _, col := range table.columns {
+				quoted = append(quoted, quoteIdent(col))
+			}
+			name += "(" + strings.Join(quoted, ", ") + ")"
+		}
+		parts = append(parts, fmt.Sprintf("%s AS (%s)", name, mapper.mapSubqueryStatement(selectStmt)))
+		mapper.commonTables[table.name] = ref
+	}
+	return "WITH " + strings.Join(parts, ", ") + " ", nil
+}
@@ -0,0 +1,156 @@
+package db
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Catalog describes the logical tables stored in the JSONB column: which
+// topics exist, which fields each topic has, and what type each field holds.
+type Catalog interface {
+	Topics() []string
+	Fields(topic string) []string
+	FieldType(topic, field string) (FieldType, bool)
+}
+
+// StaticCatalog is an in-memory Catalog keyed by topic and then by field.
+type StaticCatalog map[string]map[string]FieldType
+
+// Topics returns the catalog's topics in sorted order.
+func (c StaticCatalog) Topics() []string {
+	topics := make([]string, 0, len(c))
+	for topic := range c {
+		topics = append(topics, topic)
+	}
+	sort.Strings(topics)
+	return topics
+}
+
+// Fields returns the topic's field names in sorted order.
+func (c StaticCatalog) Fields(topic string) []string {
+	fields := make([]string, 0, len(c[topic]))
+	for field := range c[topic] {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// FieldType returns the type of a field, and whether the topic has it.
+func (c StaticCatalog) FieldType(topic, field string) (FieldType, bool) {
+	fieldType, ok := c[topic][field]
+	return fieldType, ok
+}
+
+// NewStaticCatalog builds a catalog from discovered topic schemas.
+func NewStaticCatalog(schemas map[string]*TopicSchema) StaticCatalog {
+	catalog := make(StaticCatalog, len(schemas))
+	for topic, schema := range schemas {
+		catalog[topic] = make(map[string]FieldType, len(schema.Fields))
+		for name, stats := range schema.Fields {
+			catalog[topic][name] = stats.Type
+		}
+	}
+	return catalog
+}
+
+// DiscoverCatalog samples the live table and returns its catalog.
+func DiscoverCatalog(
+	cfg DBConfig,
+	table string,
+	jsonbCol string,
+	topicKey TopicKey,
+	sampling Sampling,
+) (StaticCatalog, error) {
+
+	schemas, err := DiscoverSchema(cfg, table, jsonbCol, topicKey, sampling)
+	if err != nil {
+		return nil, err
+	}
+	return NewStaticCatalog(schemas), nil
+}
+
+// catalogFile is the on-disk layout shared by the JSON and YAML formats:
+//
+//	topics:
+//	  pump_alarm:
+//	    fields:
+//	      code: text
+//	      value: numeric
+type catalogFile struct {
+	Topics map[string]catalogTopic `json:"topics" yaml:"topics"`
+}
+
+type catalogTopic struct {
+	Fields map[string]FieldType `json:"fields" yaml:"fields"`
+}
+
+// LoadCatalogFile reads a catalog from a .json, .yaml or .yml file.
+func LoadCatalogFile(path string) (StaticCatalog, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read catalog failed: %v", err)
+	}
+
+	var file catalogFile
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		err = json.Unmarshal(data, &file)
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &file)
+	default:
+		return nil, fmt.Errorf("unsupported catalog format: %s", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parse catalog failed: %v", err)
+	}
+
+	catalog := make(StaticCatalog, len(file.Topics))
+	for topic, t := range file.Topics {
+		catalog[topic] = make(map[string]FieldType, len(t.Fields))
+		for field, fieldType := range t.Fields {
+			if fieldType == "" {
+				fieldType = FieldText
+			}
+			if !fieldType.valid() {
+				return nil, fmt.Errorf("topic %s field %s: unknown type %q", topic, field, fieldType)
+			}
+			catalog[topic][field] = fieldType
+		}
+	}
+	return catalog, nil
+}
+
+// WriteCatalogFile dumps any catalog to a .json, .yaml or .yml file in the
+// format LoadCatalogFile reads.
+func WriteCatalogFile(path string, catalog Catalog) error {
+	file := catalogFile{Topics: make(map[string]catalogTopic)}
+	for _, topic := range catalog.Topics() {
+		fields := make(map[string]FieldType)
+		for _, field := range catalog.Fields(topic) {
+			fields[field], _ = catalog.FieldType(topic, field)
+		}
+		file.Topics[topic] = catalogTopic{Fields: fields}
+	}
+
+	var data []byte
+	var err error
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		data, err = json.MarshalIndent(file, "", "  ")
+	case ".yaml", ".yml":
+		data, err = yaml.Marshal(file)
+	default:
+		return fmt.Errorf("unsupported catalog format: %s", path)
+	}
+	if err != nil {
+		return fmt.Errorf("encode catalog failed: %v", err)
+	}
+	return os.WriteFile(path, data, 0o644)
+}
@@ -0,0 +1,292 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+
+	_ "github.com/lib/pq"
+)
+
+// SampleMode selects which rows schema discovery inspects for each topic.
+type SampleMode int
+
+const (
+	// SampleFirst inspects one arbitrary row per topic.
+	SampleFirst SampleMode = iota
+	// SampleRows inspects up to Sampling.Rows rows per topic.
+	SampleRows
+	// SampleTable inspects a TABLESAMPLE SYSTEM block sample of Sampling.Percent percent.
+	SampleTable
+	// SampleFull inspects every row.
+	SampleFull
+)
+
+// Sampling configures how many rows schema discovery looks at.
+type Sampling struct {
+	Mode    SampleMode
+	Rows    int
+	Percent float64
+}
+
+// DefaultSampling is used by MapSQLShot: a bounded number of rows per topic,
+// enough for optional fields to show up without scanning the whole table.
+var DefaultSampling = Sampling{Mode: SampleRows, Rows: 1000}
+
+// FieldStats describes one JSONB field as observed across sampled rows.
+type FieldStats struct {
+	Name     string
+	Type     FieldType
+	Presence float64
+	Types    map[FieldType]int
+}
+
+// Conflict reports whether sampled rows disagree on the field's type.
+func (f *FieldStats) Conflict() bool {
+	return len(f.Types) > 1
+}
+
+// TopicSchema is the union of fields seen in the sampled rows of one topic.
+type TopicSchema struct {
+	Topic  string
+	Rows   int
+	Fields map[string]*FieldStats
+}
+
+// FieldNames returns the topic's field names in sorted order.
+func (t *TopicSchema) FieldNames() []string {
+	names := make([]string, 0, len(t.Fields))
+	for name := range t.Fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// DiscoverSchema samples rows per topic and returns, for each topic, the union
+// of their keys with per-field presence ratios and observed types. Nested
+// objects are walked and reported as dotted virtual columns such as
+// device.location.site; the elements of an array field become the child
+// topic "<topic>.<field>".
+func DiscoverSchema(
+	cfg DBConfig,
+	table string,
+	jsonbCol string,
+	topicKey TopicKey,
+	sampling Sampling,
+) (map[string]*TopicSchema, error) {
+
+	// PostgreSQL connection with DBConfig
+	db, err := sql.Open("postgres", cfg.DSN())
+	if err != nil {
+		return nil, fmt.Errorf("connect failed: %v", err)
+	}
+	defer db.Close()
+
+	return discoverSchema(db, table, jsonbCol, topicKey, sampling)
+}
+
+// discoverSchema runs schema discovery over an already open connection pool.
+func discoverSchema(
+	db *sql.DB,
+	table string,
+	jsonbCol string,
+	topicKey TopicKey,
+	sampling Sampling,
+) (map[string]*TopicSchema, error) {
+
+	samples, err := topicSamples(table, jsonbCol, topicKey, sampling)
+	if err != nil {
+		return nil, err
+	}
+	query := samples + fmt.Sprintf(`,
+        topic_rows AS (
+            SELECT topic, count(*) AS total
+            FROM topic_samples
+            GROUP BY topic
+        ),
+        fields(topic, key, value) AS (
+            SELECT ts.topic, field.key, field.value
+            FROM topic_samples ts
+            CROSS JOIN LATERAL jsonb_each(ts.data) AS field(key, value)
+            WHERE %s
+            UNION ALL
+            SELECT parent.topic, parent.key || child.suffix, child.value
+            FROM fields parent
+            CROSS JOIN LATERAL (
+                SELECT '.' || member.key, member.value
+                FROM jsonb_each(CASE WHEN jsonb_typeof(parent.value) = 'object' THEN parent.value ELSE '{}' END) AS member(key, value)
+                UNION ALL
+                SELECT '[]', elem.value
+                FROM jsonb_array_elements(CASE WHEN jsonb_typeof(parent.value) = 'array' THEN parent.value ELSE '[]' END) AS elem(value)
+            ) AS child(suffix, value)
+        )
+        SELECT field.topic, tr.total, field.key, %s AS field_type, count(*)
+        FROM fields field
+        JOIN topic_rows tr ON tr.topic = field.topic
+        GROUP BY field.topic, tr.total, field.key, field_type
+        ORDER BY field.topic, field.key;
+    `, fieldFilter(topicKey), fieldTypeExpr)
+
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("query schema failed: %v", err)
+	}
+	defer rows.Close()
+
+	result := make(map[string]*TopicSchema)
+	present := make(map[*FieldStats]int)
+
+	for rows.Next() {
+		var topic, key, fieldType string
+		var total, count int
+		if err := rows.Scan(&topic, &total, &key, &fieldType, &count); err != nil {
+			return nil, err
+		}
+		if _, ok := result[topic]; !ok {
+			result[topic] = &TopicSchema{Topic: topic, Rows: total, Fields: make(map[string]*FieldStats)}
+		}
+
+		owner, field, isElement := arrayField(topic, key)
+		schema, ok := result[owner]
+		if !ok {
+			schema = &TopicSchema{Topic: owner, Fields: make(map[string]*FieldStats)}
+			result[owner] = schema
+		}
+		if isElement {
+			// Every array element is one row of the child topic.
+			schema.Rows += count
+		}
+		if fieldType == "object" {
+			continue
+		}
+		stats, ok := schema.Fields[field]
+		if !ok {
+			stats = &FieldStats{Name: field, Types: make(map[FieldType]int)}
+			schema.Fields[field] = stats
+		}
+		present[stats] += count
+		if fieldType != "null" {
+			stats.Types[FieldType(fieldType)] += count
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, schema := range result {
+		for _, stats := range schema.Fields {
+			stats.Presence = float64(present[stats]) / float64(schema.Rows)
+			stats.Type = resolveFieldType(stats.Types)
+		}
+	}
+
+	return result, nil
+}
+
+// arrayField maps a discovered key to the topic and field it belongs to.
+// Keys below an array (readings[].value) belong to the child topic named
+// after the array (topic.readings); the array element itself (tags[]) is
+// reported as ArrayElementField and isElement.
+func arrayField(topic, key string) (owner, field string, isElement bool) {
+	i := strings.LastIndex(key, "[]")
+	if i < 0 {
+		return topic, key, false
+	}
+	owner = topic + "." + strings.ReplaceAll(key[:i], "[]", "")
+	rest := key[i+len("[]"):]
+	if rest == "" {
+		return owner, ArrayElementField, true
+	}
+	return owner, strings.TrimPrefix(rest, "."), false
+}
+
+// topicSamples builds the discovery CTE that yields (topic, data) for the
+// sampled rows of every topic.
+func topicSamples(table, jsonbCol string, key TopicKey, sampling Sampling) (string, error) {
+	topic := key.Expr("", jsonbCol)
+	present := fmt.Sprintf("%s ? '%s'", jsonbCol, key.Name)
+	if key.IsColumn {
+		present = fmt.Sprintf("%s IS NOT NULL", key.Name)
+	}
+	from := table
+	filter := fmt.Sprintf(`%[1]s IS NOT NULL
+              AND jsonb_typeof(%[1]s) = 'object'
+              AND %[1]s != '{}'
+              AND %[2]s`, jsonbCol, present)
+
+	switch sampling.Mode {
+	case SampleFirst:
+		return fmt.Sprintf(`
+        WITH RECURSIVE topic_samples AS (
+            SELECT DISTINCT ON (%[3]s)
+                   %[3]s as topic,
+                   %[2]s as data
+            FROM %[1]s
+            WHERE %[4]s
+            ORDER BY %[3]s
+        )`, from, jsonbCol, topic, filter), nil
+	case SampleRows:
+		if sampling.Rows <= 0 {
+			return "", fmt.Errorf("sample rows must be positive, got %d", sampling.Rows)
+		}
+		// The topics are found by skipping from one to the next, and each
+		// topic reads at most Rows rows, so an index on the topic
+		// expression keeps sampling from touching the whole table.
+		return fmt.Sprintf(`
+        WITH RECURSIVE topics AS (
+            (SELECT %[3]s AS topic
+             FROM %[1]s
+             WHERE %[4]s
+             ORDER BY 1
+             LIMIT 1)
+            UNION ALL
+            SELECT (SELECT %[3]s
+                    FROM %[1]s
+                    WHERE %[4]s
+                      AND %[3]s > topics.topic
+                    ORDER BY 1
+                    LIMIT 1)
+            FROM topics
+            WHERE topics.topic IS NOT NULL
+        ),
+        topic_samples AS (
+            SELECT topics.topic, sample.data
+            FROM topics
+            CROSS JOIN LATERAL (
+                SELECT %[2]s AS data
+                FROM %[1]s
+                WHERE %[4]s
+                  AND %[3]s = topics.topic
+                LIMIT %[5]d
+            ) sample
+            WHERE topics.topic IS NOT NULL
+        )`, from, jsonbCol, topic, filter, sampling.Rows), nil
+	case SampleTable:
+		if sampling.Percent <= 0 || sampling.Percent > 100 {
+			return "", fmt.Errorf("sample percent must be in (0, 100], got %g", sampling.Percent)
+		}
+		from = fmt.Sprintf("%s TABLESAMPLE SYSTEM (%g)", table, sampling.Percent)
+		fallthrough
+	case SampleFull:
+		return fmt.Sprintf(`
+        WITH RECURSIVE topic_samples AS (
+            SELECT %[3]s as topic,
+                   %[2]s as data
+            FROM %[1]s
+            WHERE %[4]s
+        )`, from, jsonbCol, topic, filter), nil
+	default:
+		return "", fmt.Errorf("unknown sample mode: %d", sampling.Mode)
+	}
+}
+
+// fieldFilter excludes the discriminator from discovered fields when it is
+// stored inside the JSONB column.
+func fieldFilter(key TopicKey) string {
+	if key.IsColumn {
+		return "TRUE"
+	}
+	return fmt.Sprintf("field.key != '%s'", key.Name)
+}
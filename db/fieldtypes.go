@@ -0,0 +1,107 @@
+package db
+
+// FieldType is the logical type of a JSONB field, inferred from sampled values.
+type FieldType string
+
+const (
+	FieldText      FieldType = "text"
+	FieldInteger   FieldType = "integer"
+	FieldNumeric   FieldType = "numeric"
+	FieldBoolean   FieldType = "boolean"
+	FieldTimestamp FieldType = "timestamp"
+	FieldDate      FieldType = "date"
+	FieldUUID      FieldType = "uuid"
+	// FieldArray is an array of scalars. Its elements are described by the
+	// child topic "<topic>.<field>", whose only field is ArrayElementField.
+	FieldArray FieldType = "array"
+	// FieldObjectArray is an array of objects. The element keys are the
+	// fields of the child topic "<topic>.<field>".
+	FieldObjectArray FieldType = "object_array"
+)
+
+// ArrayElementField names the element of a scalar array in its child topic.
+const ArrayElementField = "value"
+
+func (t FieldType) valid() bool {
+	switch t {
+	case FieldText, FieldInteger, FieldNumeric, FieldBoolean, FieldTimestamp, FieldDate, FieldUUID, FieldArray, FieldObjectArray:
+		return true
+	}
+	return false
+}
+
+// fieldTypeExpr classifies field.value from a jsonb_each expansion into a
+// FieldType name, or 'null' / 'object' for JSON nulls and objects. Strings are
+// matched against ISO-8601 timestamp and date shapes and the canonical UUID
+// form; arrays whose elements are all objects are object arrays.
+const fieldTypeExpr = `CASE jsonb_typeof(field.value)
+            WHEN 'null' THEN 'null'
+            WHEN 'object' THEN 'object'
+            WHEN 'array' THEN CASE
+                WHEN jsonb_array_length(field.value) > 0
+                 AND NOT EXISTS (SELECT 1 FROM jsonb_array_elements(field.value) AS elem(value) WHERE jsonb_typeof(elem.value) != 'object')
+                THEN 'object_array' ELSE 'array' END
+            WHEN 'number' THEN CASE WHEN field.value #>> '{}' ~ '^-?[0-9]+$' THEN 'integer' ELSE 'numeric' END
+            WHEN 'boolean' THEN 'boolean'
+            WHEN 'string' THEN CASE
+                WHEN field.value #>> '{}' ~ '^\d{4}-\d{2}-\d{2}[T ]\d{2}:\d{2}(:\d{2}(\.\d+)?)?(Z|[+-]\d{2}(:?\d{2})?)?$' THEN 'timestamp'
+                WHEN field.value #>> '{}' ~ '^\d{4}-\d{2}-\d{2}$' THEN 'date'
+                WHEN field.value #>> '{}' ~* '^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$' THEN 'uuid'
+                ELSE 'text' END
+            ELSE 'text'
+        END`
+
+// resolveFieldType picks one type for a field from the types its sampled
+// values were classified as. Integers widen to numeric, dates to timestamps
+// and arrays (often empty in some rows) to object arrays; any other
+// disagreement falls back to text.
+func resolveFieldType(types map[FieldType]int) FieldType {
+	resolved := FieldType("")
+	for fieldType := range types {
+		switch {
+		case resolved == "" || resolved == fieldType:
+			resolved = fieldType
+		case isPair(resolved, fieldType, FieldInteger, FieldNumeric):
+			resolved = FieldNumeric
+		case isPair(resolved, fieldType, FieldDate, FieldTimestamp):
+			resolved = FieldTimestamp
+		case isPair(resolved, fieldType, FieldArray, FieldObjectArray):
+			resolved = FieldObjectArray
+		default:
+			return FieldText
+		}
+	}
+	if resolved == "" {
+		return FieldText
+	}
+	return resolved
+}
+
+func isPair(a, b, x, y FieldType) bool {
+	return (a == x && b == y) || (a == y && b == x)
+}
+
+// LoadFieldTypes returns a map where topic is the key, and the value maps each jsonb field to its inferred type
+func LoadFieldTypes(
+	cfg DBConfig,
+	table string,
+	jsonbCol string,
+	topicKey TopicKey,
+	sampling Sampling,
+) (map[string]map[string]FieldType, error) {
+
+	schemas, err := DiscoverSchema(cfg, table, jsonbCol, topicKey, sampling)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]map[string]FieldType)
+	for topic, schema := range schemas {
+		result[topic] = make(map[string]FieldType)
+		for name, stats := range schema.Fields {
+			result[topic][name] = stats.Type
+		}
+	}
+
+	return result, nil
+}
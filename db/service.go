@@ -0,0 +1,158 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"sync"
+	"time"
+
+	_ "github.com/lib/pq"
+)
+
+// DefaultTTL is how long SharedSchemaService keeps a discovered catalog.
+const DefaultTTL = 5 * time.Minute
+
+// SchemaService holds a pooled connection to one store table and caches its
+// catalog, rediscovering it once the TTL expires or on Refresh. It is safe
+// for concurrent use.
+type SchemaService struct {
+	pool     *sql.DB
+	table    string
+	jsonbCol string
+	topicKey TopicKey
+	sampling Sampling
+	ttl      time.Duration
+
+	mu       sync.RWMutex
+	catalog  StaticCatalog
+	loadedAt time.Time
+
+	// refreshMu lets only one goroutine run discovery at a time; the others
+	// wait and then reuse its result.
+	refreshMu sync.Mutex
+}
+
+// NewSchemaService opens a connection pool for the table. A ttl of zero
+// keeps the catalog until Refresh is called.
+func NewSchemaService(
+	cfg DBConfig,
+	table string,
+	jsonbCol string,
+	topicKey TopicKey,
+	sampling Sampling,
+	ttl time.Duration,
+) (*SchemaService, error) {
+
+	pool, err := sql.Open("postgres", cfg.DSN())
+	if err != nil {
+		return nil, fmt.Errorf("connect failed: %v", err)
+	}
+
+	return &SchemaService{
+		pool:     pool,
+		table:    table,
+		jsonbCol: jsonbCol,
+		topicKey: topicKey,
+		sampling: sampling,
+		ttl:      ttl,
+	}, nil
+}
+
+// Catalog returns the cached catalog, discovering it first if it has not been
+// loaded yet or has expired.
+func (s *SchemaService) Catalog() (StaticCatalog, error) {
+	s.mu.RLock()
+	catalog, fresh := s.catalog, s.fresh()
+	s.mu.RUnlock()
+	if fresh {
+		return catalog, nil
+	}
+
+	s.refreshMu.Lock()
+	defer s.refreshMu.Unlock()
+
+	// Another goroutine may have refreshed while we waited.
+	s.mu.RLock()
+	catalog, fresh = s.catalog, s.fresh()
+	s.mu.RUnlock()
+	if fresh {
+		return catalog, nil
+	}
+
+	return s.refresh()
+}
+
+// Refresh rediscovers the catalog immediately.
+func (s *SchemaService) Refresh() error {
+	s.refreshMu.Lock()
+	defer s.refreshMu.Unlock()
+
+	_, err := s.refresh()
+	return err
+}
+
+// Close releases the connection pool.
+func (s *SchemaService) Close() error {
+	return s.pool.Close()
+}
+
+// fresh must be called with mu held.
+func (s *SchemaService) fresh() bool {
+	if s.catalog == nil {
+		return false
+	}
+	return s.ttl <= 0 || time.Since(s.loadedAt) < s.ttl
+}
+
+// refresh must be called with refreshMu held.
+func (s *SchemaService) refresh() (StaticCatalog, error) {
+	schemas, err := discoverSchema(s.pool, s.table, s.jsonbCol, s.topicKey, s.sampling)
+	if err != nil {
+		return nil, err
+	}
+	catalog := NewStaticCatalog(schemas)
+
+	s.mu.Lock()
+	s.catalog = catalog
+	s.loadedAt = time.Now()
+	s.mu.Unlock()
+
+	return catalog, nil
+}
+
+type serviceKey struct {
+	dsn      string
+	table    string
+	jsonbCol string
+	topicKey TopicKey
+}
+
+var (
+	servicesMu sync.Mutex
+	services   = make(map[serviceKey]*SchemaService)
+)
+
+// SharedSchemaService returns the process-wide service for the table, creating
+// it with DefaultSampling and DefaultTTL on first use.
+func SharedSchemaService(
+	cfg DBConfig,
+	table string,
+	jsonbCol string,
+	topicKey TopicKey,
+) (*SchemaService, error) {
+
+	key := serviceKey{dsn: cfg.DSN(), table: table, jsonbCol: jsonbCol, topicKey: topicKey}
+
+	servicesMu.Lock()
+	defer servicesMu.Unlock()
+
+	if service, ok := services[key]; ok {
+		return service, nil
+	}
+	service, err := NewSchemaService(cfg, table, jsonbCol, topicKey, DefaultSampling, DefaultTTL)
+	if err != nil {
+		return nil, err
+	}
+	services[key] = service
+	return service, nil
+}
@@ -0,0 +1,28 @@
+package db
+
+import (
+	"fmt"
+)
+
+// TopicKey locates the discriminator that names the logical table of a row:
+// either a key inside the JSONB column or a physical column next to it.
+type TopicKey struct {
+	Name     string
+	IsColumn bool
+}
+
+// Expr returns the SQL expression yielding a row's topic. A non-empty
+// qualifier prefixes the column it reads from.
+func (k TopicKey) Expr(qualifier, jsonbCol string) string {
+	col := jsonbCol
+	if k.IsColumn {
+		col = k.Name
+	}
+	if qualifier != "" {
+		col = qualifier + "." + col
+	}
+	if k.IsColumn {
+		return col
+	}
+	return fmt.Sprintf("(%s ->> '%s')", col, k.Name)
+}
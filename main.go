@@ -32,6 +32,8 @@ func main() {
 		"tsdb_table",
 		"payload",
 		"topic",
+		false,
+		nil,
 		originalSQL,
 	)
 	if err != nil {
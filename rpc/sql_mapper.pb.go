@@ -32,6 +32,8 @@ type MapSQLShotRequest struct {
 	PayloadCol    string                 `protobuf:"bytes,7,opt,name=payload_col,json=payloadCol,proto3" json:"payload_col,omitempty"`
 	Topic         string                 `protobuf:"bytes,8,opt,name=topic,proto3" json:"topic,omitempty"`
 	Sql           string                 `protobuf:"bytes,9,opt,name=sql,proto3" json:"sql,omitempty"`
+	TopicIsColumn bool                   `protobuf:"varint,10,opt,name=topic_is_column,json=topicIsColumn,proto3" json:"topic_is_column,omitempty"`
+	Functions     map[string]string      `protobuf:"bytes,11,rep,name=functions,proto3" json:"functions,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -129,6 +131,20 @@ func (x *MapSQLShotRequest) GetSql() string {
 	return ""
 }
 
+func (x *MapSQLShotRequest) GetTopicIsColumn() bool {
+	if x != nil {
+		return x.TopicIsColumn
+	}
+	return false
+}
+
+func (x *MapSQLShotRequest) GetFunctions() map[string]string {
+	if x != nil {
+		return x.Functions
+	}
+	return nil
+}
+
 type MapSQLShotResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	MappedSql     string                 `protobuf:"bytes,1,opt,name=mapped_sql,json=mappedSql,proto3" json:"mapped_sql,omitempty"`
@@ -185,7 +201,7 @@ var File_proto_sql_mapper_proto protoreflect.FileDescriptor
 
 const file_proto_sql_mapper_proto_rawDesc = "" +
 	"\n" +
-	"\x16proto/sql_mapper.proto\x12\x03rpc\"\xea\x01\n" +
+	"\x16proto/sql_mapper.proto\x12\x03rpc\"\x95\x03\n" +
 	"\x11MapSQLShotRequest\x12\x12\n" +
 	"\x04host\x18\x01 \x01(\tR\x04host\x12\x12\n" +
 	"\x04port\x18\x02 \x01(\x05R\x04port\x12\x16\n" +
@@ -196,7 +212,13 @@ const file_proto_sql_mapper_proto_rawDesc = "" +
 	"\vpayload_col\x18\a \x01(\tR\n" +
 	"payloadCol\x12\x14\n" +
 	"\x05topic\x18\b \x01(\tR\x05topic\x12\x10\n" +
-	"\x03sql\x18\t \x01(\tR\x03sql\"I\n" +
+	"\x03sql\x18\t \x01(\tR\x03sql\x12&\n" +
+	"\x0ftopic_is_column\x18\n" +
+	" \x01(\bR\rtopicIsColumn\x12C\n" +
+	"\tfunctions\x18\v \x03(\v2%.rpc.MapSQLShotRequest.FunctionsEntryR\tfunctions\x1a<\n" +
+	"\x0eFunctionsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"I\n" +
 	"\x12MapSQLShotResponse\x12\x1d\n" +
 	"\n" +
 	"mapped_sql\x18\x01 \x01(\tR\tmappedSql\x12\x14\n" +
@@ -217,19 +239,21 @@ func file_proto_sql_mapper_proto_rawDescGZIP() []byte {
 	return file_proto_sql_mapper_proto_rawDescData
 }
 
-var file_proto_sql_mapper_proto_msgTypes = make([]protoimpl.MessageInfo, 2)
+var file_proto_sql_mapper_proto_msgTypes = make([]protoimpl.MessageInfo, 3)
 var file_proto_sql_mapper_proto_goTypes = []any{
 	(*MapSQLShotRequest)(nil),  // 0: rpc.MapSQLShotRequest
 	(*MapSQLShotResponse)(nil), // 1: rpc.MapSQLShotResponse
+	nil,                        // 2: rpc.MapSQLShotRequest.FunctionsEntry
 }
 var file_proto_sql_mapper_proto_depIdxs = []int32{
-	0, // 0: rpc.SQLMapperService.MapSQLShot:input_type -> rpc.MapSQLShotRequest
-	1, // 1: rpc.SQLMapperService.MapSQLShot:output_type -> rpc.MapSQLShotResponse
-	1, // [1:2] is the sub-list for method output_type
-	0, // [0:1] is the sub-list for method input_type
-	0, // [0:0] is the sub-list for extension type_name
-	0, // [0:0] is the sub-list for extension extendee
-	0, // [0:0] is the sub-list for field type_name
+	2, // 0: rpc.MapSQLShotRequest.functions:type_name -> rpc.MapSQLShotRequest.FunctionsEntry
+	0, // 1: rpc.SQLMapperService.MapSQLShot:input_type -> rpc.MapSQLShotRequest
+	1, // 2: rpc.SQLMapperService.MapSQLShot:output_type -> rpc.MapSQLShotResponse
+	2, // [2:3] is the sub-list for method output_type
+	1, // [1:2] is the sub-list for method input_type
+	1, // [1:1] is the sub-list for extension type_name
+	1, // [1:1] is the sub-list for extension extendee
+	0, // [0:1] is the sub-list for field type_name
 }
 
 func init() { file_proto_sql_mapper_proto_init() }
@@ -243,7 +267,7 @@ func file_proto_sql_mapper_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_proto_sql_mapper_proto_rawDesc), len(file_proto_sql_mapper_proto_rawDesc)),
 			NumEnums:      0,
-			NumMessages:   2,
+			NumMessages:   3,
 			NumExtensions: 0,
 			NumServices:   1,
 		},